@@ -0,0 +1,239 @@
+// Milepost inventory audit: duplicate, missing and out-of-extent mileposts per ELR, for action by survey teams.
+
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"geofurlong/pkg/geocode"
+	"log"
+	"os"
+	"strconv"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	AuditNoPosts     = "no_posts"     // ELR has no mileposts.
+	AuditDuplicate   = "duplicate"    // More than one milepost with the same value on the ELR.
+	AuditGap         = "gap"          // Spacing between neighbouring mileposts (or ELR extent) exceeds the maximum.
+	AuditBeforeStart = "before_start" // Milepost value is before the start of the ELR.
+	AuditAfterEnd    = "after_end"    // Milepost value is beyond the end of the ELR.
+	defaultAuditGap  = 440            // Maximum spacing (yards) between mileposts, if not configured.
+)
+
+const (
+	QryAllELRExtents = `
+	SELECT elr, l_system, total_yards_from, total_yards_to
+	FROM cl
+	ORDER BY elr
+	`
+
+	QryAllMPValues = `
+	SELECT elr, total_yards_from
+	FROM mp
+	ORDER BY elr, total_yards_from
+	`
+
+	SQLCreateTableAudit = `
+	CREATE TABLE audit (
+		elr TEXT NOT NULL,
+		issue TEXT NOT NULL,
+		total_yards_from INTEGER NOT NULL,
+		total_yards_to INTEGER NOT NULL,
+		post_count INTEGER NOT NULL,
+		detail TEXT NOT NULL
+	)
+	`
+
+	SQLCreateIndexAudit = `
+	CREATE INDEX ix_audit_elr
+	ON audit (elr, issue)
+	`
+
+	SQLInsertAudit = `
+	INSERT INTO audit(
+		elr,
+		issue,
+		total_yards_from,
+		total_yards_to,
+		post_count,
+		detail
+	) values(?,?,?,?,?,?)
+	`
+)
+
+// AuditFinding represents a milepost inventory issue on an ELR.
+type AuditFinding struct {
+	elr       string // ELR code.
+	issue     string // Issue identified.
+	tyFrom    int    // Total yards from of the issue (the milepost value for single mileposts).
+	tyTo      int    // Total yards to of the issue (the milepost value for single mileposts).
+	postCount int    // Number of mileposts concerned.
+	detail    string // Description of the issue.
+}
+
+// auditELR returns the milepost inventory issues of the ELR, given the ELR extent and its milepost values
+// in order of increasing mileage. Gaps are assessed between neighbouring distinct mileposts within the extent,
+// and between the ELR extent and the first and last mileposts.
+func auditELR(elr string, metric bool, tyFrom, tyTo int, posts []int, maxGap int) []AuditFinding {
+	findings := make([]AuditFinding, 0)
+	mileage := func(ty int) string { return geocode.FmtTotalYards(ty, metric) }
+
+	if len(posts) == 0 {
+		return append(findings, AuditFinding{elr, AuditNoPosts, tyFrom, tyTo, 0,
+			fmt.Sprintf("no mileposts between %s and %s", mileage(tyFrom), mileage(tyTo))})
+	}
+
+	previous := tyFrom // Mileage of the previous distinct milepost within the extent, initially the ELR start.
+	for i := 0; i < len(posts); {
+		ty := posts[i]
+		count := 1
+		for i+count < len(posts) && posts[i+count] == ty {
+			count++
+		}
+		i += count
+
+		if count > 1 {
+			findings = append(findings, AuditFinding{elr, AuditDuplicate, ty, ty, count,
+				fmt.Sprintf("%d mileposts at %s", count, mileage(ty))})
+		}
+
+		switch {
+		case ty < tyFrom:
+			findings = append(findings, AuditFinding{elr, AuditBeforeStart, ty, ty, count,
+				fmt.Sprintf("milepost at %s before ELR start at %s", mileage(ty), mileage(tyFrom))})
+			continue
+		case ty > tyTo:
+			findings = append(findings, AuditFinding{elr, AuditAfterEnd, ty, ty, count,
+				fmt.Sprintf("milepost at %s beyond ELR end at %s", mileage(ty), mileage(tyTo))})
+			continue
+		}
+
+		if gap := auditGap(elr, metric, previous, ty, maxGap); gap != nil {
+			findings = append(findings, *gap)
+		}
+		previous = ty
+	}
+
+	if gap := auditGap(elr, metric, previous, tyTo, maxGap); gap != nil {
+		findings = append(findings, *gap)
+	}
+
+	return findings
+}
+
+// auditGap returns a gap finding if the spacing between the two mileages exceeds the maximum, otherwise nil.
+func auditGap(elr string, metric bool, tyFrom, tyTo, maxGap int) *AuditFinding {
+	if maxGap <= 0 || tyTo-tyFrom <= maxGap {
+		return nil
+	}
+
+	mileage := func(ty int) string { return geocode.FmtTotalYards(ty, metric) }
+
+	return &AuditFinding{elr, AuditGap, tyFrom, tyTo, 0,
+		fmt.Sprintf("%d yards without a milepost between %s and %s",
+			tyTo-tyFrom, mileage(tyFrom), mileage(tyTo))}
+}
+
+// readMileposts returns the milepost values of all ELRs in order of increasing mileage.
+func readMileposts(db *sql.DB) (map[string][]int, error) {
+	rows, err := db.Query(QryAllMPValues)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make(map[string][]int)
+	for rows.Next() {
+		var (
+			elr string
+			ty  int
+		)
+		if err := rows.Scan(&elr, &ty); err != nil {
+			return nil, err
+		}
+		posts[elr] = append(posts[elr], ty)
+	}
+
+	return posts, rows.Err()
+}
+
+// auditMileposts audits the milepost inventory of every ELR, writing the findings to the audit database and CSV.
+func auditMileposts(cfg GeofurlongConfig) {
+	log.Print("Milepost audit started")
+
+	maxGap := defaultAuditGap
+	if value, ok := cfg["audit_max_gap_y"]; ok {
+		var err error
+		maxGap, err = strconv.Atoi(value)
+		check(err)
+	}
+
+	dbELR, err := sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", cfg["cl_db"]))
+	check(err)
+	defer dbELR.Close()
+
+	dbMilepost, err := sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", cfg["mp_db"]))
+	check(err)
+	defer dbMilepost.Close()
+
+	posts, err := readMileposts(dbMilepost)
+	check(err)
+
+	deleteFile(cfg["audit_db"])
+	dbAudit, err := sql.Open("sqlite3", cfg["audit_db"])
+	check(err)
+	defer dbAudit.Close()
+
+	tx, err := dbAudit.Begin()
+	check(err)
+
+	_, err = tx.Exec(SQLCreateTableAudit)
+	check(err)
+
+	stmtInsertAudit, err := tx.Prepare(SQLInsertAudit)
+	check(err)
+	defer stmtInsertAudit.Close()
+
+	file, err := os.Create(cfg["audit_csv"])
+	check(err)
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	check(writer.Write([]string{"elr", "issue", "total_yards_from", "total_yards_to", "mileage_from", "mileage_to", "post_count", "detail"}))
+
+	rowsELR, err := dbELR.Query(QryAllELRExtents)
+	check(err)
+	defer rowsELR.Close()
+
+	count := 0
+	for rowsELR.Next() {
+		var (
+			elr, lSystem string
+			tyFrom, tyTo int
+		)
+		check(rowsELR.Scan(&elr, &lSystem, &tyFrom, &tyTo))
+		metric := lSystem == "K"
+
+		for _, f := range auditELR(elr, metric, tyFrom, tyTo, posts[elr], maxGap) {
+			_, err = stmtInsertAudit.Exec(f.elr, f.issue, f.tyFrom, f.tyTo, f.postCount, f.detail)
+			check(err)
+
+			check(writer.Write([]string{f.elr, f.issue, strconv.Itoa(f.tyFrom), strconv.Itoa(f.tyTo),
+				geocode.FmtTotalYards(f.tyFrom, metric), geocode.FmtTotalYards(f.tyTo, metric), strconv.Itoa(f.postCount), f.detail}))
+			count++
+		}
+	}
+	check(rowsELR.Err())
+
+	writer.Flush()
+	check(writer.Error())
+
+	_, err = tx.Exec(SQLCreateIndexAudit)
+	check(err)
+	check(tx.Commit())
+
+	log.Printf("Milepost audit completed, %d issues found", count)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuditELR(t *testing.T) {
+	type issue struct {
+		issue     string
+		tyFrom    int
+		tyTo      int
+		postCount int
+	}
+
+	tests := []struct {
+		name     string
+		tyFrom   int
+		tyTo     int
+		posts    []int
+		expected []issue
+	}{
+		{
+			name:     "complete",
+			tyFrom:   0,
+			tyTo:     1_320,
+			posts:    []int{0, 440, 880, 1_320},
+			expected: []issue{},
+		},
+		{
+			name:     "no posts",
+			tyFrom:   100,
+			tyTo:     2_000,
+			posts:    nil,
+			expected: []issue{{AuditNoPosts, 100, 2_000, 0}},
+		},
+		{
+			name:     "duplicate",
+			tyFrom:   0,
+			tyTo:     880,
+			posts:    []int{0, 440, 440, 440, 880},
+			expected: []issue{{AuditDuplicate, 440, 440, 3}},
+		},
+		{
+			name:     "missing posts",
+			tyFrom:   -200,
+			tyTo:     2_500,
+			posts:    []int{220, 660, 1_980},
+			expected: []issue{{AuditGap, 660, 1_980, 0}, {AuditGap, 1_980, 2_500, 0}},
+		},
+		{
+			name:     "out of extent",
+			tyFrom:   440,
+			tyTo:     880,
+			posts:    []int{0, 440, 880, 1_320, 1_320},
+			expected: []issue{{AuditBeforeStart, 0, 0, 1}, {AuditDuplicate, 1_320, 1_320, 2}, {AuditAfterEnd, 1_320, 1_320, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		got := make([]issue, 0)
+		for _, f := range auditELR("ABC", false, tt.tyFrom, tt.tyTo, tt.posts, 440) {
+			if f.elr != "ABC" || f.detail == "" {
+				t.Errorf("%s: expected ELR and detail, but got %+v", tt.name, f)
+			}
+			got = append(got, issue{f.issue, f.tyFrom, f.tyTo, f.postCount})
+		}
+
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %+v, but got %+v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestAuditELRNoGapLimit(t *testing.T) {
+	if findings := auditELR("ABC", false, 0, 10_000, []int{5_000}, 0); len(findings) != 0 {
+		t.Errorf("Expected no gaps without a limit, but got %+v", findings)
+	}
+}
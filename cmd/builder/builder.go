@@ -5,24 +5,33 @@
 package main
 
 import (
-	"geofurlong/pkg/geocode"
 	"log"
 	"strconv"
 	"sync"
 	"time"
 )
 
+// check aborts if an error is passed in.
+func check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 // main is the entry point for the GeoFurlong builder.
 func main() {
 	startTime := time.Now()
 
 	config, err := readConfig()
-	geocode.Check(err)
+	check(err)
 	log.Printf("GeoFurlong builder (version %s) started", config["version"])
 
 	// Convert the source geospatial files from Shapefile to SQLite format.
 	runPython(config, "convert.py", "")
 
+	// Audit the milepost inventory for duplicate, missing and out-of-extent mileposts.
+	auditMileposts(config)
+
 	// Compute the linear calibration, referencing mileposts against the ELR centre-line.
 	calibrate(config)
 
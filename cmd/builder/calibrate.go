@@ -23,19 +23,34 @@ type ELRFeature struct {
 	geometry orb.LineString // Geometry of the centre-line linestring.
 }
 
-// calibrationPointsToSegments pairwise transforms calibration points to calibration segments (and normalises).
-func calibrationPointsToSegments(calibPoints []geocode.CalibrationPoint) []geocode.CalibrationSegmentNormalised {
-	calibSegments := make([]geocode.CalibrationSegmentNormalised, 0, len(calibPoints)-1)
+// calibrationPointsToSegments pairwise transforms calibration points to calibration segments (and normalises),
+// interpolated by the calibration model. Segments other than spline take the slope of the segment at both ends.
+// Fewer than two calibration points give no segments.
+func calibrationPointsToSegments(calibPoints []geocode.CalibrationPoint, model geocode.CalibrationModel) []geocode.CalibrationSegmentNormalised {
+	calibSegments := make([]geocode.CalibrationSegmentNormalised, 0, max(len(calibPoints)-1, 0))
 
 	for i := 0; i < len(calibPoints)-1; i++ {
 		current := calibPoints[i]
 		next := calibPoints[i+1]
 
+		// Reported lengths are ground distances, so are compared with the ground rather than the grid offsets.
 		lenReported := float64(next.Ty-current.Ty) * geocode.YardsToMetres
-		lenMeasured := next.LoMetres - current.LoMetres
+		lenMeasured := next.LoGroundMetres - current.LoGroundMetres
 		accuracy := lenMeasured - lenReported
 		qmNormalised := (geocode.QuarterMileYards / float64(next.Ty-current.Ty)) * (lenMeasured / geocode.YardsToMetres)
 
+		// A segment between the reported extents alone is not calibrated against any milepost.
+		method := geocode.MethodMilepost
+		if current.Source == SourceELRExtent && next.Source == SourceELRExtent {
+			method = geocode.MethodProportional
+		}
+
+		slopeFrom, slopeTo := current.Slope, next.Slope
+		if model != geocode.ModelSpline && next.Ty != current.Ty {
+			slopeFrom = (next.LoMetres - current.LoMetres) / float64(next.Ty-current.Ty)
+			slopeTo = slopeFrom
+		}
+
 		segment := geocode.CalibrationSegmentNormalised{
 			TyFrom:           current.Ty,
 			TyTo:             next.Ty,
@@ -45,6 +60,12 @@ func calibrationPointsToSegments(calibPoints []geocode.CalibrationPoint) []geoco
 			LoNormalisedTo:   next.LoNormalised,
 			Accuracy:         accuracy,
 			QmNormalised:     qmNormalised,
+			SourceFrom:       current.Source,
+			SourceTo:         next.Source,
+			Method:           method,
+			Model:            model,
+			SlopeFrom:        slopeFrom,
+			SlopeTo:          slopeTo,
 		}
 
 		calibSegments = append(calibSegments, segment)
@@ -53,89 +74,190 @@ func calibrationPointsToSegments(calibPoints []geocode.CalibrationPoint) []geoco
 	return calibSegments
 }
 
+// calibrationPoints returns the calibration points of the ELR from its mileposts (in order of increasing mileage),
+// adding quasi-mileposts at either end of the ELR as required. If there are no usable mileposts, the ELR is
+// calibrated proportionally from the reported extent against the measured length, between the quasi-mileposts.
+func calibrationPoints(ef ELRFeature, mileposts []Milepost, groundLength float64) []geocode.CalibrationPoint {
+	cs := make([]geocode.CalibrationPoint, 0, len(mileposts)+2)
+	proportional := len(mileposts) == 0 && ef.tyTo > ef.tyFrom
+
+	if proportional || (len(mileposts) > 0 && mileposts[0].ty > ef.tyFrom) {
+		// The mileage of the first milepost is greater than the low mileage end of the ELR (or there is no milepost),
+		// so record a quasi-milepost at the low mileage end of the ELR.
+		csStart := geocode.CalibrationPoint{Ty: ef.tyFrom, LoMetres: 0.0, LoNormalised: 0.0, LoGroundMetres: 0.0, Source: SourceELRExtent}
+		cs = append(cs, csStart)
+	}
+
+	for _, mp := range mileposts {
+		// Record the milepost projected against the ELR geometry.
+		lo := mp.location.Measure
+		loNormalised := lo / ef.length
+		csNormalised := geocode.CalibrationPoint{Ty: mp.ty, LoMetres: lo, LoNormalised: loNormalised, LoGroundMetres: mp.ground,
+			Source: mp.source, Distance: mp.location.Distance}
+		cs = append(cs, csNormalised)
+	}
+
+	if proportional || (len(mileposts) > 0 && mileposts[len(mileposts)-1].ty < ef.tyTo) {
+		// The mileage of the last milepost is less than the high mileage end of the ELR (or there is no milepost),
+		// so record a quasi-milepost at the high mileage end of the ELR.
+		csEnd := geocode.CalibrationPoint{Ty: ef.tyTo, LoMetres: ef.length, LoNormalised: 1.0, LoGroundMetres: groundLength, Source: SourceELRExtent}
+		cs = append(cs, csEnd)
+	}
+
+	return cs
+}
+
 // Calibrator represents the database connections and prepared statements for the calibration process.
 type Calibrator struct {
-	dbELR                 *sql.DB   // ELR database.
-	dbMilepost            *sql.DB   // Milepost database.
-	dbCalibration         *sql.DB   // Calibration database.
-	stmtMilepost          *sql.Stmt // Prepared statement for milepost query.
-	rowsELR               *sql.Rows // Rows for the ELR query.
-	tx                    *sql.Tx   // Calibration database transaction.
-	stmtInsertCalibration *sql.Stmt // Prepared statement for inserting calibration rows.
-	stmtInsertStatistics  *sql.Stmt // Prepared statement for inserting calibration statistics rows.
+	dbELR                 *sql.DB                    // ELR database.
+	dbMilepost            *sql.DB                    // Milepost database.
+	dbCalibration         *sql.DB                    // Calibration database.
+	stmtMilepost          *sql.Stmt                  // Prepared statement for milepost query.
+	rowsELR               *sql.Rows                  // Rows for the ELR query.
+	tx                    *sql.Tx                    // Calibration database transaction.
+	stmtInsertCalibration *sql.Stmt                  // Prepared statement for inserting calibration rows.
+	stmtInsertStatistics  *sql.Stmt                  // Prepared statement for inserting calibration statistics rows.
+	stmtInsertProjection  *sql.Stmt                  // Prepared statement for inserting milepost projection rows.
+	stmtInsertRejection   *sql.Stmt                  // Prepared statement for inserting milepost rejection rows.
+	stmtInsertResidual    *sql.Stmt                  // Prepared statement for inserting milepost residual rows.
+	rules                 MilepostRules              // Rules for rejecting outlier mileposts.
+	window                float64                    // Extent (metres) of the milepost projection search window.
+	supplementary         map[string][]SurveyedPoint // Supplementary calibration points by ELR.
+	models                CalibrationModels          // Calibration models interpolating between calibration points.
+	grades                GradeThresholds            // Thresholds for grading calibration quality.
 }
 
 // initialise opens the centre-line and milepost databases, creates the calibration database and prepares the SQL statements.
 func (c *Calibrator) initialise(ELRFn, MilepostFn, CalibrationFn string) error {
 	var err error
 	c.dbELR, err = sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", ELRFn))
-	geocode.Check(err)
+	check(err)
 
 	c.dbMilepost, err = sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", MilepostFn))
-	geocode.Check(err)
+	check(err)
 
 	c.stmtMilepost, err = c.dbMilepost.Prepare(QryAllMPsInELR)
-	geocode.Check(err)
+	check(err)
 
 	c.rowsELR, err = c.dbELR.Query(QryAllELRs)
-	geocode.Check(err)
+	check(err)
 
 	_, err = os.Stat(CalibrationFn) // Delete calibration database if it exists.
 	if err == nil {
-		geocode.Check(os.Remove(CalibrationFn))
+		check(os.Remove(CalibrationFn))
 	}
 
 	c.dbCalibration, err = sql.Open("sqlite3", CalibrationFn)
-	geocode.Check(err)
+	check(err)
+
+	return c.createTables()
+}
 
+// createTables begins the calibration database transaction, creates the tables and prepares the insert statements.
+func (c *Calibrator) createTables() error {
+	var err error
 	c.tx, err = c.dbCalibration.Begin() // Begin a database transaction.
-	geocode.Check(err)
+	check(err)
 
 	_, err = c.tx.Exec(SQLCreateTableCalibration)
-	geocode.Check(err)
+	check(err)
 
 	_, err = c.tx.Exec(SQLCreateTableStatistics)
-	geocode.Check(err)
+	check(err)
+
+	_, err = c.tx.Exec(SQLCreateTableMilepostProjections)
+	check(err)
+
+	_, err = c.tx.Exec(SQLCreateTableMilepostRejections)
+	check(err)
+
+	_, err = c.tx.Exec(SQLCreateTableMilepostResiduals)
+	check(err)
 
 	c.stmtInsertCalibration, err = c.tx.Prepare(SQLInsertCalibration)
-	geocode.Check(err)
+	check(err)
 
 	c.stmtInsertStatistics, err = c.tx.Prepare(SQLInsertStatistics)
-	geocode.Check(err)
+	check(err)
+
+	c.stmtInsertProjection, err = c.tx.Prepare(SQLInsertMilepostProjection)
+	check(err)
+
+	c.stmtInsertRejection, err = c.tx.Prepare(SQLInsertMilepostRejection)
+	check(err)
+
+	c.stmtInsertResidual, err = c.tx.Prepare(SQLInsertMilepostResidual)
+	check(err)
 
 	return nil
 }
 
 // close closes the database connections and prepared statements.
 func (c *Calibrator) close() {
-	geocode.Check(c.dbELR.Close())
-	geocode.Check(c.dbMilepost.Close())
-	geocode.Check(c.dbCalibration.Close())
-	geocode.Check(c.stmtMilepost.Close())
-	geocode.Check(c.rowsELR.Close())
-	geocode.Check(c.stmtInsertCalibration.Close())
-	geocode.Check(c.stmtInsertStatistics.Close())
+	check(c.dbELR.Close())
+	check(c.dbMilepost.Close())
+	check(c.dbCalibration.Close())
+	check(c.stmtMilepost.Close())
+	check(c.rowsELR.Close())
+	check(c.stmtInsertCalibration.Close())
+	check(c.stmtInsertStatistics.Close())
+	check(c.stmtInsertProjection.Close())
+	check(c.stmtInsertRejection.Close())
+	check(c.stmtInsertResidual.Close())
 }
 
-// appendDB appends the calibration data to the database.
-func (c *Calibrator) appendDB(elr string, calibPoints []geocode.CalibrationPoint) error {
-	calibSegments := calibrationPointsToSegments(calibPoints)
+// appendDB appends the calibration data, with the leave-one-out validation of its mileposts, to the database.
+// An ELR with fewer than two calibration points has no calibration rows, and statistics of zero counts, not graded.
+func (c *Calibrator) appendDB(elr string, calibPoints []geocode.CalibrationPoint, model geocode.CalibrationModel, residuals []MilepostResidual) error {
+	calibSegments := calibrationPointsToSegments(calibPoints, model)
+	gradeSegments(calibPoints, calibSegments, c.grades)
 
 	// Save rows to calibration table.
 	for _, cm := range calibSegments {
 		_, err := c.stmtInsertCalibration.Exec(elr, cm.TyFrom, cm.TyTo, cm.LoMetresFrom, cm.LoMetresTo,
-			cm.LoNormalisedFrom, cm.LoNormalisedTo, cm.Accuracy, cm.QmNormalised)
-		geocode.Check(err)
+			cm.LoNormalisedFrom, cm.LoNormalisedTo, cm.Accuracy, cm.QmNormalised, cm.SourceFrom, cm.SourceTo, cm.Method.String(),
+			cm.Model.String(), cm.SlopeFrom, cm.SlopeTo, cm.Grade.String())
+		check(err)
+	}
+
+	for _, r := range residuals {
+		_, err := c.stmtInsertResidual.Exec(elr, r.milepost.ty, r.milepost.source, r.milepost.location.Measure, r.predicted, r.residual)
+		check(err)
 	}
 
 	accuracy, segLen, qmNormalised := geocode.CollateStats(calibSegments)
+	validation := validationStats(residuals)
 
 	// Save rows to calibration statistics table.
 	_, err := c.stmtInsertStatistics.Exec(elr,
 		accuracy.Count, accuracy.Min, accuracy.Max, accuracy.Mean, accuracy.Median, accuracy.StdDev,
 		segLen.Count, segLen.Min, segLen.Max, segLen.Mean, segLen.Median, segLen.StdDev,
-		qmNormalised.Count, qmNormalised.Min, qmNormalised.Max, qmNormalised.Mean, qmNormalised.Median, qmNormalised.StdDev)
-	geocode.Check(err)
+		qmNormalised.Count, qmNormalised.Min, qmNormalised.Max, qmNormalised.Mean, qmNormalised.Median, qmNormalised.StdDev,
+		validation.Count, validation.RMSE, validation.MaxAbs, gradeELR(calibSegments).String())
+	check(err)
+
+	return nil
+}
+
+// appendProjections appends the milepost projections to the database.
+func (c *Calibrator) appendProjections(elr string, mileposts []Milepost) error {
+	for _, mp := range mileposts {
+		_, err := c.stmtInsertProjection.Exec(elr, mp.ty, mp.point.X(), mp.point.Y(),
+			mp.location.Distance, mp.location.Measure, mp.method, mp.source, mp.priority)
+		check(err)
+	}
+
+	return nil
+}
+
+// appendRejections appends the milepost rejections to the database.
+func (c *Calibrator) appendRejections(elr string, rejections []MilepostRejection) error {
+	for _, r := range rejections {
+		mp := r.milepost
+		_, err := c.stmtInsertRejection.Exec(elr, mp.ty, mp.point.X(), mp.point.Y(),
+			mp.location.Distance, mp.location.Measure, r.rule, r.detail, mp.source)
+		check(err)
+	}
 
 	return nil
 }
@@ -151,46 +273,62 @@ func (c *Calibrator) computeAndSaveCalibration() error {
 		)
 
 		err := c.rowsELR.Scan(&ef.elr, &ef.tyFrom, &ef.tyTo, &ef.length, wkb.Scanner(&ef.geometry))
-		geocode.Check(err)
+		check(err)
 		rowsMP, err := c.stmtMilepost.Query(ef.elr)
-		geocode.Check(err)
+		check(err)
 		defer rowsMP.Close()
-		gotFirstMP := false
+
+		groundLength := geocode.GroundLength(ef.geometry)
 
 		// Initial size based on 99% of ELRs having 300 or less mileposts in total.
-		cs := make([]geocode.CalibrationPoint, 0, 300)
+		surveyed := make([]SurveyedPoint, 0, 300)
 
 		for rowsMP.Next() {
 			// Loop through all milepost records for the current ELR.
 			err = rowsMP.Scan(&tyMP, wkb.Scanner(&pointMP))
-			geocode.Check(err)
-
-			if !gotFirstMP {
-				gotFirstMP = true
-				if tyMP > ef.tyFrom {
-					// The mileage of the first milepost is greater than the low mileage end of the ELR,
-					// so record a quasi-milepost at the low mileage end of the ELR.
-					csStart := geocode.CalibrationPoint{Ty: ef.tyFrom, LoMetres: 0.0, LoNormalised: 0.0}
-					cs = append(cs, csStart)
-				}
-			}
+			check(err)
+			surveyed = append(surveyed, SurveyedPoint{ty: tyMP, point: pointMP, source: SourceMilepost, priority: milepostPriority})
+		}
 
-			// Record the milepost projected against the ELR geometry.
-			nearestPt, _ := geocode.NearestPointOnLine(&ef.geometry, pointMP)
-			lo := geocode.DistanceAlongLine(&ef.geometry, nearestPt)
-			loNormalised := lo / ef.length
-			csNormalised := geocode.CalibrationPoint{Ty: tyMP, LoMetres: lo, LoNormalised: loNormalised}
-			cs = append(cs, csNormalised)
+		// Override and add to the mileposts with any supplementary points. Duplicated milepost values (as reported
+		// by the milepost audit) are resolved on projection, retaining the milepost nearest the centre-line.
+		merged := mergeSurveyedPoints(surveyed, c.supplementary[ef.elr])
+
+		mileposts := make([]Milepost, 0, len(merged))
+		projected := make([]Milepost, 0, len(surveyed))
+		duplicates := make([]MilepostRejection, 0)
+		projector := newMilepostProjector(ef, c.window, c.rules.MaxOffset)
+
+		for _, candidates := range merged {
+			// Project the milepost against the ELR geometry.
+			mp, rejected, ok := projector.projectNearest(candidates)
+			if !ok {
+				log.Printf("ELR %s has no geometry to project milepost at total yards %d\n", ef.elr, candidates[0].ty)
+				continue
+			}
+			mileposts = append(mileposts, mp)
+			projected = append(projected, mp)
+			for _, r := range rejected {
+				projected = append(projected, r.milepost)
+			}
+			duplicates = append(duplicates, rejected...)
 		}
 
-		if tyMP < ef.tyTo {
-			// The mileage of the last milepost is less than the high mileage end of the ELR,
-			// so record a quasi-milepost at the high mileage end of the ELR.
-			csEnd := geocode.CalibrationPoint{Ty: ef.tyTo, LoMetres: ef.length, LoNormalised: 1.0}
-			cs = append(cs, csEnd)
+		c.appendProjections(ef.elr, projected)
+		mileposts, rejections := filterMileposts(mileposts, c.rules)
+		c.appendRejections(ef.elr, append(duplicates, rejections...))
+
+		if len(mileposts) == 0 && ef.tyTo > ef.tyFrom {
+			log.Printf("ELR %s has no usable mileposts, so is calibrated proportionally\n", ef.elr)
 		}
 
-		c.appendDB(ef.elr, cs)
+		model := c.models.forELR(ef.elr)
+		cs := applyCalibrationModel(calibrationPoints(ef, mileposts, groundLength), model, ef.length)
+		if len(cs) < 2 {
+			// Such as an ELR of zero reported length, with at most a single milepost.
+			log.Printf("ELR %s has fewer than two calibration points, so is not calibrated\n", ef.elr)
+		}
+		c.appendDB(ef.elr, cs, model, leaveOneOut(ef, mileposts, groundLength, model))
 	}
 
 	return nil
@@ -199,15 +337,24 @@ func (c *Calibrator) computeAndSaveCalibration() error {
 // finalise commits the database transaction and performs optimisation.
 func (c *Calibrator) finalise() error {
 	_, err := c.tx.Exec(SQLCreateIndexCalibration)
-	geocode.Check(err)
+	check(err)
 
 	_, err = c.tx.Exec(SQLCreateIndexStatistics)
-	geocode.Check(err)
+	check(err)
+
+	_, err = c.tx.Exec(SQLCreateIndexMilepostProjections)
+	check(err)
+
+	_, err = c.tx.Exec(SQLCreateIndexMilepostRejections)
+	check(err)
+
+	_, err = c.tx.Exec(SQLCreateIndexMilepostResiduals)
+	check(err)
 
-	geocode.Check(c.tx.Commit())
+	check(c.tx.Commit())
 
 	_, err = c.dbCalibration.Exec(SQLVacuumAnalyze)
-	geocode.Check(err)
+	check(err)
 
 	return nil
 }
@@ -215,10 +362,22 @@ func (c *Calibrator) finalise() error {
 // calibrate performs the calibration process, referencing mileposts against ELR centre-lines, and saving to a database.
 func calibrate(cfg GeofurlongConfig) {
 	log.Print("Calibration started")
-	c := Calibrator{}
+	rules, err := readMilepostRules(cfg)
+	check(err)
+	window, err := readProjectionWindow(cfg)
+	check(err)
+	supplementary, err := readSupplementary(cfg["calib_supplementary_fn"])
+	check(err)
+	log.Printf("Read supplementary calibration points for %d ELRs", len(supplementary))
+	models, err := readCalibrationModels(cfg)
+	check(err)
+	log.Printf("Calibration model %s, overridden for %d ELRs", models.Default, len(models.Overrides))
+	grades, err := readGradeThresholds(cfg)
+	check(err)
+	c := Calibrator{rules: rules, window: window, supplementary: supplementary, models: models, grades: grades}
 	c.initialise(cfg["cl_db"], cfg["mp_db"], cfg["calib_db"])
 	defer c.close()
-	geocode.Check(c.computeAndSaveCalibration())
-	geocode.Check(c.finalise())
+	check(c.computeAndSaveCalibration())
+	check(c.finalise())
 	log.Print("Calibration completed")
 }
@@ -13,7 +13,14 @@ const (
 		linear_offset_from_norm REAL NOT NULL,
 		linear_offset_to_norm REAL NOT NULL,
 		accuracy REAL NOT NULL,
-		quarter_mile_norm_y REAL NOT NULL
+		quarter_mile_norm_y REAL NOT NULL,
+		source_from TEXT NOT NULL,
+		source_to TEXT NOT NULL,
+		method TEXT NOT NULL,
+		model TEXT NOT NULL,
+		slope_from REAL NOT NULL,
+		slope_to REAL NOT NULL,
+		grade TEXT NOT NULL
 	)
 `
 
@@ -32,8 +39,15 @@ const (
 		linear_offset_from_norm, 
 		linear_offset_to_norm, 
 		accuracy, 
-		quarter_mile_norm_y
-	) values(?,?,?,?,?,?,?,?,?)
+		quarter_mile_norm_y, 
+		source_from, 
+		source_to, 
+		method, 
+		model, 
+		slope_from, 
+		slope_to, 
+		grade
+	) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 	`
 
 	SQLCreateTableStatistics = `
@@ -56,7 +70,11 @@ const (
 		quarter_mile_norm_max REAL NOT NULL,
 		quarter_mile_norm_mean REAL NOT NULL,
 		quarter_mile_norm_median REAL NOT NULL,
-		quarter_mile_norm_std REAL NOT NULL
+		quarter_mile_norm_std REAL NOT NULL,
+		loo_count INTEGER NOT NULL,
+		loo_rmse_m REAL NOT NULL,
+		loo_max_abs_m REAL NOT NULL,
+		grade TEXT NOT NULL
 	)
 	`
 
@@ -85,8 +103,105 @@ const (
 		quarter_mile_norm_max, 
 		quarter_mile_norm_mean, 
 		quarter_mile_norm_median, 
-		quarter_mile_norm_std
-	) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		quarter_mile_norm_std, 
+		loo_count, 
+		loo_rmse_m, 
+		loo_max_abs_m, 
+		grade
+	) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+	`
+
+	SQLCreateTableMilepostProjections = `
+	CREATE TABLE milepost_projections (
+		elr TEXT NOT NULL,
+		total_yards INTEGER NOT NULL,
+		easting REAL NOT NULL,
+		northing REAL NOT NULL,
+		offset_m REAL NOT NULL,
+		linear_offset_m REAL NOT NULL,
+		method TEXT NOT NULL,
+		source TEXT NOT NULL,
+		priority INTEGER NOT NULL
+	)
+	`
+
+	SQLCreateIndexMilepostProjections = `
+	CREATE INDEX ix_milepost_projections_elr 
+	ON milepost_projections (elr, total_yards)
+	`
+
+	SQLInsertMilepostProjection = `
+	INSERT INTO milepost_projections(
+		elr, 
+		total_yards, 
+		easting, 
+		northing, 
+		offset_m, 
+		linear_offset_m, 
+		method, 
+		source, 
+		priority
+	) values(?,?,?,?,?,?,?,?,?)
+	`
+
+	SQLCreateTableMilepostRejections = `
+	CREATE TABLE milepost_rejections (
+		elr TEXT NOT NULL,
+		total_yards INTEGER NOT NULL,
+		easting REAL NOT NULL,
+		northing REAL NOT NULL,
+		offset_m REAL NOT NULL,
+		linear_offset_m REAL NOT NULL,
+		rule TEXT NOT NULL,
+		detail TEXT NOT NULL,
+		source TEXT NOT NULL
+	)
+	`
+
+	SQLCreateIndexMilepostRejections = `
+	CREATE INDEX ix_milepost_rejections_elr 
+	ON milepost_rejections (elr, total_yards)
+	`
+
+	SQLInsertMilepostRejection = `
+	INSERT INTO milepost_rejections(
+		elr, 
+		total_yards, 
+		easting, 
+		northing, 
+		offset_m, 
+		linear_offset_m, 
+		rule, 
+		detail, 
+		source
+	) values(?,?,?,?,?,?,?,?,?)
+	`
+
+	SQLCreateTableMilepostResiduals = `
+	CREATE TABLE milepost_residuals (
+		elr TEXT NOT NULL,
+		total_yards INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		linear_offset_m REAL NOT NULL,
+		predicted_linear_offset_m REAL NOT NULL,
+		residual_m REAL NOT NULL
+	)
+	`
+
+	SQLCreateIndexMilepostResiduals = `
+	CREATE INDEX ix_milepost_residuals_elr 
+	ON milepost_residuals (elr, total_yards)
+	`
+
+	SQLInsertMilepostResidual = `
+	INSERT INTO milepost_residuals(
+		elr, 
+		total_yards, 
+		source, 
+		linear_offset_m, 
+		predicted_linear_offset_m, 
+		residual_m
+	) values(?,?,?,?,?,?)
 	`
 
 	QryAllELRs = `
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"database/sql"
 	"geofurlong/pkg/geocode"
 	"math"
+	"reflect"
 	"testing"
 )
 
@@ -11,20 +13,23 @@ func TestCalibrationPointsToSegments(t *testing.T) {
 
 	calibrationPoints := []geocode.CalibrationPoint{
 		{
-			Ty:           100,
-			LoMetres:     1_000,
-			LoNormalised: 444},
+			Ty:             100,
+			LoMetres:       1_000,
+			LoNormalised:   444,
+			LoGroundMetres: 1_000},
 		{
-			Ty:           200,
-			LoMetres:     1_105,
-			LoNormalised: 555},
+			Ty:             200,
+			LoMetres:       1_105,
+			LoNormalised:   555,
+			LoGroundMetres: 1_105},
 		{
-			Ty:           8_888,
-			LoMetres:     9_999,
-			LoNormalised: 666},
+			Ty:             8_888,
+			LoMetres:       9_999,
+			LoNormalised:   666,
+			LoGroundMetres: 9_999},
 	}
 
-	calibrationSegments := calibrationPointsToSegments(calibrationPoints)
+	calibrationSegments := calibrationPointsToSegments(calibrationPoints, geocode.ModelLinear)
 
 	if len(calibrationPoints)-1 != len(calibrationSegments) {
 		t.Errorf("Expected length %v, but got %v", len(calibrationPoints)-1, len(calibrationSegments))
@@ -75,3 +80,142 @@ func TestCalibrationPointsToSegments(t *testing.T) {
 	}
 
 }
+
+func TestCalibrationPointsToSegmentsGround(t *testing.T) {
+	const Epsilon = 1e-6
+
+	// Grid offsets on the central meridian are shorter than ground offsets by the scale factor.
+	const scale = 0.999_601_271_7
+	calibrationPoints := []geocode.CalibrationPoint{
+		{Ty: 0, LoMetres: 0, LoNormalised: 0, LoGroundMetres: 0},
+		{Ty: 1_760, LoMetres: geocode.MetresInMile * scale, LoNormalised: 1, LoGroundMetres: geocode.MetresInMile},
+	}
+
+	calibrationSegments := calibrationPointsToSegments(calibrationPoints, geocode.ModelLinear)
+
+	if math.Abs(calibrationSegments[0].Accuracy) > Epsilon {
+		t.Errorf("Expected accuracy 0 measured on the ground, but got %v", calibrationSegments[0].Accuracy)
+	}
+
+	if math.Abs(calibrationSegments[0].QmNormalised-geocode.QuarterMileYards) > Epsilon {
+		t.Errorf("Expected %v, but got %v", geocode.QuarterMileYards, calibrationSegments[0].QmNormalised)
+	}
+
+	if calibrationSegments[0].LoMetresTo != geocode.MetresInMile*scale {
+		t.Errorf("Expected grid offset %v retained for positioning, but got %v", geocode.MetresInMile*scale, calibrationSegments[0].LoMetresTo)
+	}
+}
+
+func TestCalibrationPointsFallback(t *testing.T) {
+	const Epsilon = 1e-6
+
+	ef := ELRFeature{elr: "ABC", tyFrom: 220, tyTo: 2_420, length: 2_000}
+
+	tests := []struct {
+		name      string
+		mileposts []Milepost
+		expected  []int
+		methods   []geocode.CalibrationMethod
+	}{
+		{
+			name:      "no mileposts",
+			mileposts: nil,
+			expected:  []int{220, 2_420},
+			methods:   []geocode.CalibrationMethod{geocode.MethodProportional},
+		},
+		{
+			name:      "mileposts within extent",
+			mileposts: []Milepost{{ty: 440, location: geocode.LineLocation{Measure: 200}, ground: 200, source: SourceMilepost}},
+			expected:  []int{220, 440, 2_420},
+			methods:   []geocode.CalibrationMethod{geocode.MethodMilepost, geocode.MethodMilepost},
+		},
+		{
+			name: "mileposts at extent",
+			mileposts: []Milepost{
+				{ty: 220, location: geocode.LineLocation{Measure: 0}, ground: 0, source: SourceMilepost},
+				{ty: 2_420, location: geocode.LineLocation{Measure: 2_000}, ground: 2_000, source: SourceMilepost},
+			},
+			expected: []int{220, 2_420},
+			methods:  []geocode.CalibrationMethod{geocode.MethodMilepost},
+		},
+	}
+
+	for _, tt := range tests {
+		cs := calibrationPoints(ef, tt.mileposts, 2_001)
+
+		got := make([]int, 0, len(cs))
+		for _, c := range cs {
+			got = append(got, c.Ty)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected calibration points at %v, but got %v", tt.name, tt.expected, got)
+		}
+
+		methods := make([]geocode.CalibrationMethod, 0, len(cs))
+		for _, s := range calibrationPointsToSegments(cs, geocode.ModelLinear) {
+			methods = append(methods, s.Method)
+		}
+		if !reflect.DeepEqual(methods, tt.methods) {
+			t.Errorf("%s: expected methods %v, but got %v", tt.name, tt.methods, methods)
+		}
+	}
+
+	// Proportional calibration spans the whole ELR, comparing the measured and reported lengths.
+	segments := calibrationPointsToSegments(calibrationPoints(ef, nil, 2_001), geocode.ModelLinear)
+	if expected := 2_001 - 2_200*geocode.YardsToMetres; math.Abs(segments[0].Accuracy-expected) > Epsilon {
+		t.Errorf("Expected accuracy %v, but got %v", expected, segments[0].Accuracy)
+	}
+
+	if cs := calibrationPoints(ELRFeature{tyFrom: 100, tyTo: 100}, nil, 0); len(cs) > 1 {
+		t.Errorf("Expected no proportional calibration of a zero length ELR, but got %+v", cs)
+	}
+}
+
+func TestAppendDB(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	c := &Calibrator{dbCalibration: db, grades: defaultGradeThresholds}
+	if err := c.createTables(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.tx.Rollback()
+
+	single := Milepost{ty: 100, location: geocode.LineLocation{Measure: 0}, source: SourceMilepost}
+	tests := []struct {
+		name      string
+		ef        ELRFeature
+		mileposts []Milepost
+		segments  int
+		grade     string
+	}{
+		{"zero length without mileposts", ELRFeature{elr: "ZER", tyFrom: 100, tyTo: 100}, nil, 0, ""},
+		{"zero length with a single milepost", ELRFeature{elr: "ONE", tyFrom: 100, tyTo: 100}, []Milepost{single}, 0, ""},
+		{"proportional", ELRFeature{elr: "PRO", tyFrom: 0, tyTo: 1_760, length: 1_609.344}, nil, 1, "E"},
+	}
+
+	for _, tt := range tests {
+		model := geocode.ModelLinear
+		cs := applyCalibrationModel(calibrationPoints(tt.ef, tt.mileposts, tt.ef.length), model, tt.ef.length)
+		if err := c.appendDB(tt.ef.elr, cs, model, leaveOneOut(tt.ef, tt.mileposts, tt.ef.length, model)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		var segments, count int
+		var grade string
+		if err := c.tx.QueryRow("SELECT COUNT(*) FROM calibration WHERE elr = ?", tt.ef.elr).Scan(&segments); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.tx.QueryRow("SELECT accuracy_count, grade FROM statistics WHERE elr = ?", tt.ef.elr).Scan(&count, &grade); err != nil {
+			t.Fatalf("%s: expected statistics row: %v", tt.name, err)
+		}
+		if segments != tt.segments || count != tt.segments || grade != tt.grade {
+			t.Errorf("%s: expected %d segments graded %q, but got %d segments (%d in statistics) graded %q",
+				tt.name, tt.segments, tt.grade, segments, count, grade)
+		}
+	}
+}
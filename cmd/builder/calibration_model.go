@@ -0,0 +1,230 @@
+// Calibration models fitting linear offset against mileage through the calibration points of an ELR.
+
+package main
+
+import (
+	"fmt"
+	"geofurlong/pkg/geocode"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	huberTuning      = 1.345  // Huber loss tuning constant, as a multiple of the residual scale.
+	madToStdDev      = 0.6745 // Median absolute deviation of a standard normal distribution.
+	robustMinScale   = 1.0    // Minimum residual scale (metres), so that exactly fitting points do not stall reweighting.
+	robustIterations = 50     // Maximum number of reweighting iterations of the robust fit.
+	robustTolerance  = 1e-9   // Change in fitted values (metres) below which the robust fit has converged.
+)
+
+// CalibrationModels represents the calibration model used for all ELRs, with overrides for individual ELRs.
+type CalibrationModels struct {
+	Default   geocode.CalibrationModel            // Model used unless overridden.
+	Overrides map[string]geocode.CalibrationModel // Model by ELR, overriding the default.
+}
+
+// forELR returns the calibration model of the ELR.
+func (m CalibrationModels) forELR(elr string) geocode.CalibrationModel {
+	if model, ok := m.Overrides[elr]; ok {
+		return model
+	}
+	return m.Default
+}
+
+// readCalibrationModels returns the calibration models from the configuration, linear if absent.
+// Overrides are given as comma-separated ELR=model pairs.
+func readCalibrationModels(cfg GeofurlongConfig) (CalibrationModels, error) {
+	models := CalibrationModels{Default: geocode.ModelLinear, Overrides: map[string]geocode.CalibrationModel{}}
+
+	if value, ok := cfg["calib_model"]; ok && value != "" {
+		model, err := geocode.ParseCalibrationModel(value)
+		if err != nil {
+			return CalibrationModels{}, fmt.Errorf("invalid calib_model: %w", err)
+		}
+		models.Default = model
+	}
+
+	for _, pair := range strings.Split(cfg["calib_model_overrides"], ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		elr, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return CalibrationModels{}, fmt.Errorf("invalid calib_model_overrides entry %q, expected ELR=model", pair)
+		}
+
+		model, err := geocode.ParseCalibrationModel(strings.TrimSpace(name))
+		if err != nil {
+			return CalibrationModels{}, fmt.Errorf("invalid calib_model_overrides entry %q: %w", pair, err)
+		}
+		models.Overrides[strings.ToUpper(strings.TrimSpace(elr))] = model
+	}
+
+	return models, nil
+}
+
+// applyCalibrationModel returns the calibration points (in order of increasing mileage) fitted by the model.
+// The linear model uses the points as given. The spline model adds the slope at each point. The robust model
+// replaces the linear offsets of the mileposts with those of a line fitted to all points, limited to the length
+// of the centre-line, leaving the quasi-mileposts at the ELR extent pinned to its ends, and the ground offsets as
+// observed so that segment accuracy continues to reflect the mileposts. If the fitted line does not increase with
+// mileage, the robust model falls back to the linear model.
+func applyCalibrationModel(cs []geocode.CalibrationPoint, model geocode.CalibrationModel, length float64) []geocode.CalibrationPoint {
+	fitted := make([]geocode.CalibrationPoint, len(cs))
+	copy(fitted, cs)
+
+	switch model {
+	case geocode.ModelSpline:
+		for i, slope := range monotoneSlopes(fitted) {
+			fitted[i].Slope = slope
+		}
+
+	case geocode.ModelRobust:
+		intercept, slope, ok := fitRobustLine(fitted)
+		if !ok || slope <= 0 {
+			return fitted
+		}
+		for i := range fitted {
+			if fitted[i].Source == SourceELRExtent {
+				continue
+			}
+			fitted[i].LoMetres = min(max(intercept+slope*float64(fitted[i].Ty), 0), length)
+			if length > 0 {
+				fitted[i].LoNormalised = fitted[i].LoMetres / length
+			}
+			fitted[i].Slope = slope
+		}
+	}
+
+	return fitted
+}
+
+// monotoneSlopes returns the slopes (metres per yard) at the calibration points of a monotone cubic
+// interpolation (Fritsch-Carlson), which does not overshoot between points.
+func monotoneSlopes(cs []geocode.CalibrationPoint) []float64 {
+	n := len(cs)
+	slopes := make([]float64, n)
+	if n < 2 {
+		return slopes
+	}
+
+	// Secant slopes of each segment.
+	secants := make([]float64, n-1)
+	for i := range secants {
+		if dx := float64(cs[i+1].Ty - cs[i].Ty); dx != 0 {
+			secants[i] = (cs[i+1].LoMetres - cs[i].LoMetres) / dx
+		}
+	}
+
+	slopes[0], slopes[n-1] = secants[0], secants[n-2]
+	for i := 1; i < n-1; i++ {
+		if secants[i-1]*secants[i] > 0 {
+			slopes[i] = (secants[i-1] + secants[i]) / 2
+		}
+	}
+
+	// Limit the slopes so that each segment remains monotone.
+	for i, secant := range secants {
+		if secant == 0 {
+			slopes[i], slopes[i+1] = 0, 0
+			continue
+		}
+
+		alpha, beta := slopes[i]/secant, slopes[i+1]/secant
+		if h := math.Hypot(alpha, beta); h > 3 {
+			slopes[i], slopes[i+1] = 3*alpha/h*secant, 3*beta/h*secant
+		}
+	}
+
+	return slopes
+}
+
+// fitRobustLine returns the intercept and slope of a line of linear offset against mileage fitted to the
+// calibration points by iteratively reweighted least squares with the Huber loss, so that outlying points
+// carry little weight. Returns false if fewer than two distinct mileages are given.
+func fitRobustLine(cs []geocode.CalibrationPoint) (float64, float64, bool) {
+	weights := make([]float64, len(cs))
+	for i := range weights {
+		weights[i] = 1
+	}
+	residuals := make([]float64, len(cs))
+
+	intercept, slope, ok := fitWeightedLine(cs, weights)
+	if !ok {
+		return 0, 0, false
+	}
+
+	for iteration := 0; iteration < robustIterations; iteration++ {
+		for i, c := range cs {
+			residuals[i] = math.Abs(c.LoMetres - (intercept + slope*float64(c.Ty)))
+		}
+		scale := max(medianOf(residuals)/madToStdDev, robustMinScale)
+
+		for i, r := range residuals {
+			weights[i] = min(1, huberTuning*scale/r)
+		}
+
+		nextIntercept, nextSlope, ok := fitWeightedLine(cs, weights)
+		if !ok {
+			break
+		}
+
+		converged := true
+		for _, c := range cs {
+			ty := float64(c.Ty)
+			if math.Abs((nextIntercept+nextSlope*ty)-(intercept+slope*ty)) > robustTolerance {
+				converged = false
+				break
+			}
+		}
+		intercept, slope = nextIntercept, nextSlope
+		if converged {
+			break
+		}
+	}
+
+	return intercept, slope, true
+}
+
+// fitWeightedLine returns the intercept and slope of the weighted least squares line of linear offset against
+// mileage. Returns false if fewer than two distinct mileages carry weight.
+func fitWeightedLine(cs []geocode.CalibrationPoint, weights []float64) (float64, float64, bool) {
+	var sumW, sumX, sumY float64
+	for i, c := range cs {
+		sumW += weights[i]
+		sumX += weights[i] * float64(c.Ty)
+		sumY += weights[i] * c.LoMetres
+	}
+	if sumW == 0 {
+		return 0, 0, false
+	}
+	meanX, meanY := sumX/sumW, sumY/sumW
+
+	var sxx, sxy float64
+	for i, c := range cs {
+		dx := float64(c.Ty) - meanX
+		sxx += weights[i] * dx * dx
+		sxy += weights[i] * dx * (c.LoMetres - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, false
+	}
+
+	slope := sxy / sxx
+	return meanY - slope*meanX, slope, true
+}
+
+// medianOf returns the median of the values, without reordering them.
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"geofurlong/pkg/geocode"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestApplyCalibrationModelSpline(t *testing.T) {
+	const Epsilon = 1e-9
+
+	cs := []geocode.CalibrationPoint{
+		{Ty: 0, LoMetres: 0},
+		{Ty: 440, LoMetres: 400},
+		{Ty: 880, LoMetres: 400},
+		{Ty: 1_320, LoMetres: 1_000},
+	}
+
+	fitted := applyCalibrationModel(cs, geocode.ModelSpline, 1_000)
+	expected := []float64{400.0 / 440, 0, 0, 600.0 / 440}
+	for i, c := range fitted {
+		if math.Abs(c.Slope-expected[i]) > Epsilon {
+			t.Errorf("point %d: expected slope %v, but got %v", i, expected[i], c.Slope)
+		}
+		if c.LoMetres != cs[i].LoMetres {
+			t.Errorf("point %d: expected linear offset %v unchanged, but got %v", i, cs[i].LoMetres, c.LoMetres)
+		}
+	}
+
+	// Spline segments carry the fitted slopes at either end, other models the slope of the segment.
+	segments := calibrationPointsToSegments(fitted, geocode.ModelSpline)
+	if s := segments[2]; s.Model != geocode.ModelSpline || s.SlopeFrom != 0 || math.Abs(s.SlopeTo-600.0/440) > Epsilon {
+		t.Errorf("Expected spline segment slopes 0 and %v, but got %+v", 600.0/440, s)
+	}
+
+	segments = calibrationPointsToSegments(fitted, geocode.ModelLinear)
+	if s := segments[2]; s.Model != geocode.ModelLinear || math.Abs(s.SlopeFrom-600.0/440) > Epsilon || s.SlopeTo != s.SlopeFrom {
+		t.Errorf("Expected linear segment slopes %v, but got %+v", 600.0/440, s)
+	}
+}
+
+func TestApplyCalibrationModelRobust(t *testing.T) {
+	const Epsilon = 1e-6
+
+	// Mileposts every quarter mile on a line 2% longer than reported, with one milepost misplaced by 150 metres.
+	cs := make([]geocode.CalibrationPoint, 0, 9)
+	for i := 0; i <= 8; i++ {
+		ty := i * geocode.QuarterMileYards
+		lo := float64(ty) * geocode.YardsToMetres * 1.02
+		if i == 4 {
+			lo += 150
+		}
+		cs = append(cs, geocode.CalibrationPoint{Ty: ty, LoMetres: lo, LoGroundMetres: lo})
+	}
+
+	// Least squares would shift the line by 150 / 9 metres, whereas the Huber loss caps the misplaced milepost's influence.
+	const tolerance = 0.5
+	fitted := applyCalibrationModel(cs, geocode.ModelRobust, 3_300)
+	for i, c := range fitted {
+		expected := float64(c.Ty) * geocode.YardsToMetres * 1.02
+		if math.Abs(c.LoMetres-expected) > tolerance {
+			t.Errorf("point %d: expected fitted linear offset %v, but got %v", i, expected, c.LoMetres)
+		}
+		if math.Abs(c.LoNormalised-c.LoMetres/3_300) > Epsilon {
+			t.Errorf("point %d: expected normalised offset %v, but got %v", i, c.LoMetres/3_300, c.LoNormalised)
+		}
+		if c.LoGroundMetres != cs[i].LoGroundMetres {
+			t.Errorf("point %d: expected observed ground offset %v retained, but got %v", i, cs[i].LoGroundMetres, c.LoGroundMetres)
+		}
+	}
+
+	// A line which does not increase with mileage falls back to the points as given.
+	decreasing := []geocode.CalibrationPoint{{Ty: 0, LoMetres: 500}, {Ty: 440, LoMetres: 300}, {Ty: 880, LoMetres: 100}}
+	if fitted := applyCalibrationModel(decreasing, geocode.ModelRobust, 500); !reflect.DeepEqual(fitted, decreasing) {
+		t.Errorf("Expected %+v, but got %+v", decreasing, fitted)
+	}
+
+	// Too few points to fit are left unchanged.
+	single := []geocode.CalibrationPoint{{Ty: 100, LoMetres: 50}}
+	if fitted := applyCalibrationModel(single, geocode.ModelRobust, 100); !reflect.DeepEqual(fitted, single) {
+		t.Errorf("Expected %+v, but got %+v", single, fitted)
+	}
+}
+
+func TestApplyCalibrationModelRobustExtent(t *testing.T) {
+	const length = 2_000.0
+
+	// Mileposts every quarter mile between quasi-mileposts at the ELR extent, with a misplaced milepost near either end.
+	tests := []struct {
+		name    string
+		outlier int
+		shift   float64
+	}{
+		{"outlier near start", 1, -350},
+		{"outlier near end", 4, 350},
+	}
+
+	for _, tt := range tests {
+		cs := []geocode.CalibrationPoint{{Ty: 0, LoMetres: 0, Source: SourceELRExtent}}
+		for i := 1; i <= 4; i++ {
+			ty := i * geocode.QuarterMileYards
+			lo := float64(ty) * geocode.YardsToMetres
+			if i == tt.outlier {
+				lo += tt.shift
+			}
+			cs = append(cs, geocode.CalibrationPoint{Ty: ty, LoMetres: lo, LoGroundMetres: lo, Source: SourceMilepost})
+		}
+		cs = append(cs, geocode.CalibrationPoint{Ty: 2_200, LoMetres: length, LoNormalised: 1, Source: SourceELRExtent})
+
+		fitted := applyCalibrationModel(cs, geocode.ModelRobust, length)
+		if fitted[0].LoMetres != 0 || fitted[len(fitted)-1].LoMetres != length {
+			t.Errorf("%s: expected extent pinned at 0 and %v, but got %v and %v",
+				tt.name, length, fitted[0].LoMetres, fitted[len(fitted)-1].LoMetres)
+		}
+
+		for i, c := range fitted {
+			if c.LoMetres < 0 || c.LoMetres > length {
+				t.Errorf("%s: point %d: expected linear offset within [0, %v], but got %v", tt.name, i, length, c.LoMetres)
+			}
+			if i > 0 && c.LoMetres < fitted[i-1].LoMetres {
+				t.Errorf("%s: point %d: expected linear offsets not to decrease, but got %v after %v",
+					tt.name, i, c.LoMetres, fitted[i-1].LoMetres)
+			}
+		}
+
+		// Every mileage within the extent is positioned on the centre-line.
+		segments := calibrationSegments(calibrationPointsToSegments(fitted, geocode.ModelRobust))
+		for ty := 0; ty <= 2_200; ty += 110 {
+			lo, ok := geocode.InterpolateCalibration(segments, ty)
+			if !ok || lo < 0 || lo > length {
+				t.Errorf("%s: mileage %d: expected linear offset within [0, %v], but got %v", tt.name, ty, length, lo)
+			}
+		}
+	}
+}
+
+func TestReadCalibrationModels(t *testing.T) {
+	cfg := GeofurlongConfig{"calib_model": "spline", "calib_model_overrides": "abc=robust, DEF1=linear"}
+	models, err := readCalibrationModels(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]geocode.CalibrationModel{"ABC": geocode.ModelRobust, "DEF1": geocode.ModelLinear, "GHI": geocode.ModelSpline}
+	for elr, model := range expected {
+		if got := models.forELR(elr); got != model {
+			t.Errorf("ELR %s: expected model %v, but got %v", elr, model, got)
+		}
+	}
+
+	if models, err := readCalibrationModels(GeofurlongConfig{}); err != nil || models.forELR("ABC") != geocode.ModelLinear {
+		t.Errorf("Expected linear model when absent, but got %+v, %v", models, err)
+	}
+
+	for _, cfg := range []GeofurlongConfig{{"calib_model": "cubic"}, {"calib_model_overrides": "ABC"}, {"calib_model_overrides": "ABC=cubic"}} {
+		if _, err := readCalibrationModels(cfg); err == nil {
+			t.Errorf("Expected error for %v", cfg)
+		}
+	}
+}
@@ -26,16 +26,17 @@ CREATE TABLE elr_tmp (elr TEXT NOT NULL, route TEXT NOT NULL, section TEXT, rema
 
 CREATE TABLE elr (elr TEXT, l_system TEXT, shape_length_m FLOAT, total_yards_from INTEGER, total_yards_to INTEGER,
                   route TEXT NOT NULL, section TEXT, remarks TEXT, quail_book TEXT NOT NULL, grouping TEXT, neighbours TEXT,
-                  geometry BLOB NOT NULL, PRIMARY KEY (elr));
+                  grade TEXT, geometry BLOB NOT NULL, PRIMARY KEY (elr));
 
 
--- Join manually maintained non-geospatial ELR attributes with geospatial ELR centre-line data.
+-- Join manually maintained non-geospatial ELR attributes and calibration grade with geospatial ELR centre-line data.
 INSERT INTO elr
     SELECT cl.elr, cl.l_system, cl.shape_length_m, cl.total_yards_from, cl.total_yards_to,
            elr_tmp.route, elr_tmp.section, elr_tmp.remarks, elr_tmp.quail_book, elr_tmp.grouping, elr_tmp.neighbours,
-           cl.geometry
+           statistics.grade, cl.geometry
     FROM ext_cl.cl AS cl
-    LEFT OUTER JOIN elr_tmp ON cl.elr = elr_tmp.elr;
+    LEFT OUTER JOIN elr_tmp ON cl.elr = elr_tmp.elr
+    LEFT OUTER JOIN ext_calib.statistics AS statistics ON cl.elr = statistics.elr;
 
 DROP TABLE elr_tmp;
 
@@ -43,7 +44,7 @@ CREATE VIEW elr_metric AS SELECT elr FROM elr WHERE l_system="K" ORDER BY elr;
 
 -- Subset of calibration stored.
 -- For external GIS systems (e.g. PostGIS), use the normalised linear offset values for point/substring operations.
-CREATE TABLE calibration AS SELECT elr, total_yards_from, total_yards_to, linear_offset_from_m, linear_offset_to_m, CAST(accuracy AS INT) AS accuracy FROM ext_calib.calibration;
+CREATE TABLE calibration AS SELECT elr, total_yards_from, total_yards_to, linear_offset_from_m, linear_offset_to_m, CAST(accuracy AS INT) AS accuracy, method, model, slope_from, slope_to, grade FROM ext_calib.calibration;
 CREATE UNIQUE INDEX ix_calibration ON calibration (elr, total_yards_from, total_yards_to);
 
 -- Version table.
@@ -73,5 +74,5 @@ VACUUM;
 	}
 
 	_, err := geocode.NewGeocoder(gcCfg)
-	geocode.Check(err)
+	check(err)
 }
@@ -4,7 +4,6 @@ package main
 
 import (
 	"bytes"
-	"geofurlong/pkg/geocode"
 	"log"
 	"os"
 	"os/exec"
@@ -13,15 +12,15 @@ import (
 // runPython executes the external Python script, logging its output.
 func runPython(cfg GeofurlongConfig, scriptFn string, params string) {
 	originalDir, err := os.Getwd()
-	geocode.Check(err)
+	check(err)
 
 	err = os.Chdir(cfg["scripts_dir"])
-	geocode.Check(err)
+	check(err)
 
 	// Ensure the working directory is reset after the function completes.
 	defer func() {
 		err = os.Chdir(originalDir)
-		geocode.Check(err)
+		check(err)
 	}()
 
 	var cmd *exec.Cmd
@@ -34,17 +33,17 @@ func runPython(cfg GeofurlongConfig, scriptFn string, params string) {
 
 	output, err := cmd.CombinedOutput()
 	log.Println(string(output))
-	geocode.Check(err)
+	check(err)
 }
 
 // deleteFile deletes the specified file if it exists.
 func deleteFile(filename string) {
 	if _, err := os.Stat(filename); err == nil {
 		err = os.Remove(filename)
-		geocode.Check(err)
+		check(err)
 	} else if os.IsNotExist(err) {
 	} else {
-		geocode.Check(err)
+		check(err)
 	}
 }
 
@@ -53,5 +52,5 @@ func runSQLiteCommand(db string, inputScript string) {
 	cmd := exec.Command("sqlite3", "-bail", db)
 	cmd.Stdin = bytes.NewBuffer([]byte(inputScript))
 	err := cmd.Run()
-	geocode.Check(err)
+	check(err)
 }
@@ -20,6 +20,7 @@ const (
 	NRRegionID         = 1 // Network Rail region grouping code.
 	CountryAdminAreaID = 2 // Country and admin area grouping code.
 	DistrictPlaceID    = 3 // District and place grouping code.
+	CalibrationGradeID = 4 // Calibration grade grouping code.
 )
 
 // GazetteerRow represents an unaggregated row in the Gazetteer database.
@@ -52,28 +53,29 @@ type AggregatorConfig struct {
 
 // Aggregator represents the Gazetteer Aggregator.
 type Aggregator struct {
-	dbGaz   *sql.DB         // Unaggregated gazetteer database.
-	stmtGaz *sql.Stmt       // Prepared statement for unaggregated gazetteer database.
-	elrs    []string        // All ELR codes.
-	metrics map[string]bool // Metric ELRs.
-	buf     strings.Builder // Output buffer.
+	dbGaz   *sql.DB           // Unaggregated gazetteer database.
+	stmtGaz *sql.Stmt         // Prepared statement for unaggregated gazetteer database.
+	elrs    []string          // All ELR codes.
+	metrics map[string]bool   // Metric ELRs.
+	gc      *geocode.Geocoder // Geocoder, for the calibration of each ELR.
+	buf     strings.Builder   // Output buffer.
 }
 
 // NewAggregator creates a new Gazetteer Aggregator.
 func NewAggregator(config AggregatorConfig) *Aggregator {
 	dbGaz, err := sql.Open("sqlite3", config.unaggregatedDb)
-	geocode.Check(err)
+	check(err)
 	stmtGaz, err := dbGaz.Prepare("SELECT total_yards, nr_region, country, admin_area, county_district, place_name, distance_m FROM gazetteer_summary WHERE elr=? ORDER BY total_yards")
-	geocode.Check(err)
+	check(err)
 
 	gc, err := geocode.NewGeocoder(config.gcConfig)
-	geocode.Check(err)
+	check(err)
 
 	elrs := gc.AllELRs()
 	metrics := gc.MetricELRs()
 
 	buf := strings.Builder{}
-	return &Aggregator{dbGaz, stmtGaz, elrs, metrics, buf}
+	return &Aggregator{dbGaz, stmtGaz, elrs, metrics, gc, buf}
 }
 
 // Close closes the database and prepared statement.
@@ -91,7 +93,7 @@ func (a *Aggregator) tyToStr(elr string, tyFrom int, tyTo int) (string, string)
 // aggregate aggregates (groups) the gazetteer for a given ELR.
 func (a *Aggregator) aggregate(elr string) {
 	rows, err := a.stmtGaz.Query(elr)
-	geocode.Check(err)
+	check(err)
 	defer rows.Close()
 
 	// Build a slice of gazetteer rows for the given ELR.
@@ -101,7 +103,7 @@ func (a *Aggregator) aggregate(elr string) {
 		row := GazetteerRow{}
 		err := rows.Scan(&row.ty, &row.region, &row.country, &row.adminArea, &row.district, &row.place, &row.distance)
 		gazetteerRows = append(gazetteerRows, row)
-		geocode.Check(err)
+		check(err)
 	}
 
 	groupsNRRegion := aggregateDataText(gazetteerRows, func(r GazetteerRow) string { return r.region })
@@ -134,26 +136,33 @@ func (a *Aggregator) aggregate(elr string) {
 			district, place, gPlace.minDistance, gPlace.maxDistance, gPlace.meanDistance))
 	}
 
+	groupsGrade := aggregateGrades(a.gc.ELRs[elr].CalibrationSegments)
+	for _, gGrade := range groupsGrade {
+		mileageFrom, mileageTo := a.tyToStr(elr, gGrade.tyFrom, gGrade.tyTo)
+		a.buf.WriteString(fmt.Sprintf("%s,%d,%d,%d,%s,%s,%s,,,,\n",
+			elr, CalibrationGradeID, gGrade.tyFrom, gGrade.tyTo, mileageFrom, mileageTo, gGrade.value))
+	}
+
 }
 
 // outputCSV outputs the aggregated gazetteer as a CSV file.
 func (a *Aggregator) outputCSV(fn string) {
 	file, err := os.Create(fn)
-	geocode.Check(err)
+	check(err)
 	defer file.Close()
 
 	_, err = file.WriteString(a.buf.String())
-	geocode.Check(err)
+	check(err)
 
 	err = file.Sync()
-	geocode.Check(err)
+	check(err)
 }
 
 // csvToDb builds a SQLite gazetteer database for 22y resolution, including helper tables.
 func csvToDb(csvFn string, dbFn string, sqlFn string) {
 	deleteFile(dbFn)
 	sql, err := os.ReadFile(sqlFn)
-	geocode.Check(err)
+	check(err)
 	modifiedSql := strings.Replace(string(sql), "gazetteer_aggregated.csv", csvFn, -1)
 	runSQLiteCommand(dbFn, modifiedSql)
 }
@@ -189,6 +198,28 @@ func aggregateDataText(data []GazetteerRow, valueFunc func(GazetteerRow) string)
 	return groups
 }
 
+// aggregateGrades aggregates the calibration segments (in order of increasing mileage) by grade.
+func aggregateGrades(calibrationSegments []geocode.CalibrationSegment) []AggregateGroup {
+	var groups []AggregateGroup
+
+	for _, c := range calibrationSegments {
+		grade := c.Grade.String()
+		last := len(groups) - 1
+		if last >= 0 && groups[last].value == grade {
+			groups[last].tyTo = c.TyTo
+			continue
+		}
+
+		if last >= 0 {
+			// Subtract 1 from the shared calibration point to avoid overlapping groups.
+			groups[last].tyTo = c.TyFrom - 1
+		}
+		groups = append(groups, AggregateGroup{tyFrom: c.TyFrom, tyTo: c.TyTo, value: grade})
+	}
+
+	return groups
+}
+
 // aggregateDataNumeric aggregates the data for a given numeric value.
 func aggregateDataNumeric(data []GazetteerRow, valueFunc func(GazetteerRow) string) []AggregateGroup {
 	var groups []AggregateGroup
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"geofurlong/pkg/geocode"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateGrades(t *testing.T) {
+	segments := []geocode.CalibrationSegment{
+		{TyFrom: 0, TyTo: 440, Grade: geocode.GradeA},
+		{TyFrom: 440, TyTo: 880, Grade: geocode.GradeA},
+		{TyFrom: 880, TyTo: 1_320, Grade: geocode.GradeD},
+		{TyFrom: 1_320, TyTo: 1_760, Grade: geocode.GradeB},
+	}
+
+	expected := []AggregateGroup{
+		{tyFrom: 0, tyTo: 879, value: "A"},
+		{tyFrom: 880, tyTo: 1_319, value: "D"},
+		{tyFrom: 1_320, tyTo: 1_760, value: "B"},
+	}
+
+	if groups := aggregateGrades(segments); !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, groups)
+	}
+
+	if groups := aggregateGrades(nil); len(groups) != 0 {
+		t.Errorf("Expected no groups without calibration, but got %+v", groups)
+	}
+}
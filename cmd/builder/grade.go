@@ -0,0 +1,115 @@
+// Calibration quality grading of segments and ELRs, from configurable thresholds.
+
+package main
+
+import (
+	"fmt"
+	"geofurlong/pkg/geocode"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// gradeLimits represents the maximum values of a calibration measure for grades A to D, beyond which is grade E.
+type gradeLimits [4]float64
+
+// GradeThresholds represents the thresholds on each calibration measure for grading.
+type GradeThresholds struct {
+	Accuracy    gradeLimits // Magnitude of the accuracy (metres).
+	QmDeviation gradeLimits // Magnitude of the deviation of the normalised quarter mile from 440 yards (yards).
+	Spacing     gradeLimits // Spacing between the calibration points (yards).
+	Offset      gradeLimits // Greater distance of either milepost from the centre-line (metres).
+}
+
+// defaultGradeThresholds are the grading thresholds used where not configured.
+var defaultGradeThresholds = GradeThresholds{
+	Accuracy:    gradeLimits{5, 10, 20, 50},
+	QmDeviation: gradeLimits{11, 22, 44, 110},
+	Spacing:     gradeLimits{440, 880, 1_760, 3_520},
+	Offset:      gradeLimits{5, 10, 25, 50},
+}
+
+// readGradeThresholds returns the grading thresholds from the configuration, each as comma-separated limits for
+// grades A to D in increasing order, using the default limits of any threshold absent.
+func readGradeThresholds(cfg GeofurlongConfig) (GradeThresholds, error) {
+	thresholds := defaultGradeThresholds
+
+	for _, t := range []struct {
+		key    string
+		limits *gradeLimits
+	}{
+		{"grade_accuracy_m", &thresholds.Accuracy},
+		{"grade_qm_deviation_y", &thresholds.QmDeviation},
+		{"grade_spacing_y", &thresholds.Spacing},
+		{"grade_offset_m", &thresholds.Offset},
+	} {
+		key, limits := t.key, t.limits
+		value, ok := cfg[key]
+		if !ok || value == "" {
+			continue
+		}
+
+		fields := strings.Split(value, ",")
+		if len(fields) != len(limits) {
+			return GradeThresholds{}, fmt.Errorf("invalid %s: expected %d limits, but got %d", key, len(limits), len(fields))
+		}
+		for i, field := range fields {
+			limit, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return GradeThresholds{}, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if i > 0 && limit < limits[i-1] {
+				return GradeThresholds{}, fmt.Errorf("invalid %s: limits must not decrease", key)
+			}
+			limits[i] = limit
+		}
+	}
+
+	return thresholds, nil
+}
+
+// grade returns the best grade whose limit the value does not exceed.
+func (l gradeLimits) grade(value float64) geocode.CalibrationGrade {
+	for i, limit := range l {
+		if value <= limit {
+			return geocode.GradeA + geocode.CalibrationGrade(i)
+		}
+	}
+	return geocode.GradeE
+}
+
+// gradeSegments grades each calibration segment by the worst of its measures, given the calibration points it was
+// transformed from. Segments not calibrated against any milepost are graded E.
+func gradeSegments(calibPoints []geocode.CalibrationPoint, calibSegments []geocode.CalibrationSegmentNormalised, thresholds GradeThresholds) {
+	for i := range calibSegments {
+		s := &calibSegments[i]
+		if s.Method == geocode.MethodProportional {
+			s.Grade = geocode.GradeE
+			continue
+		}
+
+		offset := max(calibPoints[i].Distance, calibPoints[i+1].Distance)
+		s.Grade = max(
+			thresholds.Accuracy.grade(math.Abs(s.Accuracy)),
+			thresholds.QmDeviation.grade(math.Abs(s.QmNormalised-geocode.QuarterMileYards)),
+			thresholds.Spacing.grade(float64(s.TyTo-s.TyFrom)),
+			thresholds.Offset.grade(offset),
+		)
+	}
+}
+
+// gradeELR returns the grade of the ELR, as the mean of its segment grades weighted by mileage, rounded to
+// the worse grade when midway. Returns not graded if the ELR has no calibrated mileage.
+func gradeELR(calibSegments []geocode.CalibrationSegmentNormalised) geocode.CalibrationGrade {
+	var sum, length float64
+	for _, s := range calibSegments {
+		yards := float64(s.TyTo - s.TyFrom)
+		sum += yards * float64(s.Grade)
+		length += yards
+	}
+	if length == 0 {
+		return geocode.GradeNone
+	}
+
+	return geocode.CalibrationGrade(math.Floor(sum/length + 0.5))
+}
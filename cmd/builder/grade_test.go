@@ -0,0 +1,107 @@
+package main
+
+import (
+	"geofurlong/pkg/geocode"
+	"testing"
+)
+
+func TestGradeSegments(t *testing.T) {
+	thresholds := defaultGradeThresholds
+
+	tests := []struct {
+		name     string
+		segment  geocode.CalibrationSegmentNormalised
+		distance float64
+		expected geocode.CalibrationGrade
+	}{
+		{
+			name:     "close quarter mile",
+			segment:  geocode.CalibrationSegmentNormalised{TyFrom: 0, TyTo: 440, Accuracy: 2, QmNormalised: 442},
+			distance: 3,
+			expected: geocode.GradeA,
+		},
+		{
+			name:     "inaccurate",
+			segment:  geocode.CalibrationSegmentNormalised{TyFrom: 0, TyTo: 440, Accuracy: -15, QmNormalised: 440},
+			distance: 3,
+			expected: geocode.GradeC,
+		},
+		{
+			name:     "sparse mileposts",
+			segment:  geocode.CalibrationSegmentNormalised{TyFrom: 0, TyTo: 1_320, Accuracy: 2, QmNormalised: 440},
+			distance: 3,
+			expected: geocode.GradeC,
+		},
+		{
+			name:     "milepost far from centre-line",
+			segment:  geocode.CalibrationSegmentNormalised{TyFrom: 0, TyTo: 440, Accuracy: 2, QmNormalised: 440},
+			distance: 80,
+			expected: geocode.GradeE,
+		},
+		{
+			name:     "distorted quarter mile",
+			segment:  geocode.CalibrationSegmentNormalised{TyFrom: 0, TyTo: 440, Accuracy: 2, QmNormalised: 500},
+			distance: 3,
+			expected: geocode.GradeD,
+		},
+		{
+			name: "proportional",
+			segment: geocode.CalibrationSegmentNormalised{TyFrom: 0, TyTo: 440, Accuracy: 0, QmNormalised: 440,
+				Method: geocode.MethodProportional},
+			expected: geocode.GradeE,
+		},
+	}
+
+	for _, tt := range tests {
+		points := []geocode.CalibrationPoint{{Ty: tt.segment.TyFrom}, {Ty: tt.segment.TyTo, Distance: tt.distance}}
+		segments := []geocode.CalibrationSegmentNormalised{tt.segment}
+		gradeSegments(points, segments, thresholds)
+		if segments[0].Grade != tt.expected {
+			t.Errorf("%s: expected grade %v, but got %v", tt.name, tt.expected, segments[0].Grade)
+		}
+	}
+}
+
+func TestGradeELR(t *testing.T) {
+	segments := []geocode.CalibrationSegmentNormalised{
+		{TyFrom: 0, TyTo: 1_320, Grade: geocode.GradeA},
+		{TyFrom: 1_320, TyTo: 1_760, Grade: geocode.GradeE},
+	}
+	if grade := gradeELR(segments); grade != geocode.GradeB {
+		t.Errorf("Expected grade B weighted by mileage, but got %v", grade)
+	}
+
+	// Midway between grades is rounded to the worse grade.
+	segments[0].TyTo, segments[1].TyFrom = 880, 880
+	segments[1].Grade = geocode.GradeB
+	if grade := gradeELR(segments); grade != geocode.GradeB {
+		t.Errorf("Expected grade B when midway, but got %v", grade)
+	}
+
+	if grade := gradeELR(nil); grade != geocode.GradeNone {
+		t.Errorf("Expected no grade without calibration, but got %v", grade)
+	}
+}
+
+func TestReadGradeThresholds(t *testing.T) {
+	thresholds, err := readGradeThresholds(GeofurlongConfig{"grade_accuracy_m": "1, 2, 3, 4"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := defaultGradeThresholds
+	expected.Accuracy = gradeLimits{1, 2, 3, 4}
+	if thresholds != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, thresholds)
+	}
+
+	for _, cfg := range []GeofurlongConfig{
+		{"grade_offset_m": "1,2,3"},
+		{"grade_offset_m": "1,2,3,far"},
+		{"grade_spacing_y": "880,440,1760,3520"},
+	} {
+		if _, err := readGradeThresholds(cfg); err == nil {
+			t.Errorf("Expected error for %v", cfg)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Projection of mileposts onto an ELR centre-line, constrained to progress along lines which double back.
+
+package main
+
+import (
+	"fmt"
+	"geofurlong/pkg/geocode"
+	"sort"
+	"strconv"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	ProjectionWindowed = "windowed" // Projected within the window following the previous milepost.
+	ProjectionGlobal   = "global"   // Projected onto the nearest part of the whole centre-line.
+)
+
+// milepostProjector projects successive mileposts (in order of increasing mileage) onto an ELR centre-line.
+// Each milepost is first projected within a window from the previous milepost's linear offset to beyond the
+// offset expected from its mileage, so that a loop or a line running alongside itself is not snapped to the
+// wrong part of the centre-line, falling back to the nearest part of the whole centre-line.
+type milepostProjector struct {
+	line       orb.LineString // Geometry of the centre-line linestring.
+	window     float64        // Extent (metres) of the search window beyond the expected linear offset, zero to disable.
+	maxOffset  float64        // Maximum distance (metres) of a windowed projection from the centre-line, zero for no limit.
+	tyPrevious int            // Total yards of the previous milepost, initially the ELR start.
+	loPrevious float64        // Linear offset (metres) of the previous milepost, initially the ELR start.
+}
+
+// newMilepostProjector returns a projector for the mileposts of the ELR.
+func newMilepostProjector(ef ELRFeature, window, maxOffset float64) *milepostProjector {
+	return &milepostProjector{line: ef.geometry, window: window, maxOffset: maxOffset, tyPrevious: ef.tyFrom}
+}
+
+// project projects the milepost onto the centre-line, recording the method used.
+// Returns false if the centre-line has no geometry to project onto.
+func (p *milepostProjector) project(ty int, point orb.Point) (Milepost, bool) {
+	mp, inRange, ok := p.locate(ty, point)
+	if ok {
+		p.advance(mp, inRange)
+	}
+
+	return mp, ok
+}
+
+// projectNearest projects the candidate surveyed points of a single mileage onto the centre-line, returning the
+// milepost nearest the centre-line, with the other candidates rejected as duplicates. Equidistant candidates are
+// resolved by Easting then Northing, so that the milepost retained does not depend on the order of the candidates.
+// Returns false if the centre-line has no geometry to project onto.
+func (p *milepostProjector) projectNearest(candidates []SurveyedPoint) (Milepost, []MilepostRejection, bool) {
+	type projection struct {
+		mp      Milepost
+		inRange bool
+	}
+
+	projections := make([]projection, 0, len(candidates))
+	for _, sp := range candidates {
+		mp, inRange, ok := p.locate(sp.ty, sp.point)
+		if !ok {
+			return Milepost{}, nil, false
+		}
+		mp.source, mp.priority = sp.source, sp.priority
+		projections = append(projections, projection{mp, inRange})
+	}
+
+	sort.Slice(projections, func(i, j int) bool {
+		a, b := projections[i].mp, projections[j].mp
+		switch {
+		case a.location.Distance != b.location.Distance:
+			return a.location.Distance < b.location.Distance
+		case a.point.X() != b.point.X():
+			return a.point.X() < b.point.X()
+		default:
+			return a.point.Y() < b.point.Y()
+		}
+	})
+
+	nearest := projections[0]
+	p.advance(nearest.mp, nearest.inRange)
+
+	duplicates := make([]MilepostRejection, 0, len(projections)-1)
+	for _, d := range projections[1:] {
+		duplicates = append(duplicates, MilepostRejection{d.mp, RuleDuplicate,
+			fmt.Sprintf("offset %.1fm, retained milepost offset %.1fm", d.mp.location.Distance, nearest.mp.location.Distance)})
+	}
+
+	return nearest.mp, duplicates, true
+}
+
+// locate projects the milepost onto the centre-line without advancing the window, also reporting whether the
+// projection is within range to advance the window. Returns false if the centre-line has no geometry to project onto.
+func (p *milepostProjector) locate(ty int, point orb.Point) (Milepost, bool, bool) {
+	windowEnd := p.loPrevious + float64(ty-p.tyPrevious)*geocode.YardsToMetres + p.window
+	if p.window > 0 {
+		loc, ok := geocode.LocateOnLineWithin(p.line, point, p.loPrevious, windowEnd)
+		if ok && (p.maxOffset == 0 || loc.Distance <= p.maxOffset) {
+			return p.milepost(ty, point, loc, ProjectionWindowed), true, true
+		}
+	}
+
+	loc, ok := geocode.LocateOnLine(p.line, point)
+	if !ok {
+		return Milepost{}, false, false
+	}
+
+	// A fallback beyond the window, such as onto the return leg of a hairpin, must not drag the window after it.
+	return p.milepost(ty, point, loc, ProjectionGlobal), loc.Measure <= windowEnd, true
+}
+
+// milepost returns the milepost projected to the location by the method.
+func (p *milepostProjector) milepost(ty int, point orb.Point, loc geocode.LineLocation, method string) Milepost {
+	return Milepost{ty: ty, point: point, location: loc, ground: geocode.GroundMeasure(p.line, loc), method: method}
+}
+
+// advance advances the window to the projected milepost, if within range and not behind the window.
+func (p *milepostProjector) advance(mp Milepost, inRange bool) {
+	if inRange && mp.location.Measure >= p.loPrevious {
+		p.tyPrevious, p.loPrevious = mp.ty, mp.location.Measure
+	}
+}
+
+// readProjectionWindow returns the extent of the milepost projection search window from the configuration,
+// zero (always projecting onto the whole centre-line) if absent.
+func readProjectionWindow(cfg GeofurlongConfig) (float64, error) {
+	value, ok := cfg["calib_window_m"]
+	if !ok {
+		return 0, nil
+	}
+
+	window, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid calib_window_m: %w", err)
+	}
+
+	return window, nil
+}
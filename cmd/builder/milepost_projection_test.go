@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestMilepostProjector(t *testing.T) {
+	// Hairpin, returning 50 metres alongside the outward leg.
+	ef := ELRFeature{elr: "ABC", tyFrom: 0, tyTo: 2_242, geometry: orb.LineString{{0, 0}, {1_000, 0}, {1_000, 50}, {0, 50}}}
+
+	tests := []struct {
+		name     string
+		window   float64
+		ty       []int
+		points   []orb.Point
+		measures []float64
+		methods  []string
+	}{
+		{
+			name:     "windowed keeps to the outward leg",
+			window:   100,
+			ty:       []int{220, 2_000},
+			points:   []orb.Point{{200, 30}, {170, 45}},
+			measures: []float64{200, 1_880},
+			methods:  []string{ProjectionWindowed, ProjectionWindowed},
+		},
+		{
+			name:     "nearest snaps to the return leg",
+			window:   0,
+			ty:       []int{220, 2_000},
+			points:   []orb.Point{{200, 30}, {170, 45}},
+			measures: []float64{1_850, 1_880},
+			methods:  []string{ProjectionGlobal, ProjectionGlobal},
+		},
+		{
+			name:     "falls back to nearest beyond maximum offset",
+			window:   100,
+			ty:       []int{220, 440},
+			points:   []orb.Point{{200, 10}, {900, -40}},
+			measures: []float64{200, 900},
+			methods:  []string{ProjectionWindowed, ProjectionGlobal},
+		},
+		{
+			name:     "fallback onto the return leg does not advance the window",
+			window:   100,
+			ty:       []int{220, 440, 660},
+			points:   []orb.Point{{200, 10}, {400, 45}, {600, 5}},
+			measures: []float64{200, 1_650, 600},
+			methods:  []string{ProjectionWindowed, ProjectionGlobal, ProjectionWindowed},
+		},
+	}
+
+	for _, tt := range tests {
+		projector := newMilepostProjector(ef, tt.window, 35)
+		for i, ty := range tt.ty {
+			mp, ok := projector.project(ty, tt.points[i])
+			if !ok {
+				t.Fatalf("%s: expected projection of milepost %d", tt.name, ty)
+			}
+			if math.Abs(mp.location.Measure-tt.measures[i]) > 1e-6 || mp.method != tt.methods[i] {
+				t.Errorf("%s: milepost %d expected %v by %s, but got %v by %s",
+					tt.name, ty, tt.measures[i], tt.methods[i], mp.location.Measure, mp.method)
+			}
+		}
+	}
+}
+
+func TestReadProjectionWindow(t *testing.T) {
+	if window, err := readProjectionWindow(GeofurlongConfig{"calib_window_m": "400"}); err != nil || window != 400 {
+		t.Errorf("Expected 400, but got %v, %v", window, err)
+	}
+
+	if window, err := readProjectionWindow(GeofurlongConfig{}); err != nil || window != 0 {
+		t.Errorf("Expected window disabled when absent, but got %v, %v", window, err)
+	}
+
+	if _, err := readProjectionWindow(GeofurlongConfig{"calib_window_m": "wide"}); err == nil {
+		t.Error("Expected error for invalid calib_window_m")
+	}
+}
+
+func TestMilepostProjectorNearest(t *testing.T) {
+	ef := ELRFeature{elr: "ABC", tyFrom: 0, tyTo: 1_100, geometry: orb.LineString{{0, 0}, {1_000, 0}}}
+
+	tests := []struct {
+		name       string
+		candidates []SurveyedPoint
+		retained   orb.Point
+		rejected   []orb.Point
+	}{
+		{
+			name:       "single milepost",
+			candidates: []SurveyedPoint{{ty: 440, point: orb.Point{400, 20}, source: SourceMilepost}},
+			retained:   orb.Point{400, 20},
+			rejected:   []orb.Point{},
+		},
+		{
+			name: "nearest the centre-line",
+			candidates: []SurveyedPoint{
+				{ty: 440, point: orb.Point{400, 20}, source: SourceMilepost},
+				{ty: 440, point: orb.Point{410, 5}, source: SourceMilepost},
+				{ty: 440, point: orb.Point{390, -30}, source: SourceMilepost},
+			},
+			retained: orb.Point{410, 5},
+			rejected: []orb.Point{{400, 20}, {390, -30}},
+		},
+		{
+			name: "equidistant by Easting",
+			candidates: []SurveyedPoint{
+				{ty: 440, point: orb.Point{400, 5}, source: SourceMilepost},
+				{ty: 440, point: orb.Point{380, -5}, source: SourceMilepost},
+			},
+			retained: orb.Point{380, -5},
+			rejected: []orb.Point{{400, 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		// The milepost retained must not depend on the order of the candidates.
+		for _, reversed := range []bool{false, true} {
+			candidates := append([]SurveyedPoint(nil), tt.candidates...)
+			if reversed {
+				for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+					candidates[i], candidates[j] = candidates[j], candidates[i]
+				}
+			}
+
+			projector := newMilepostProjector(ef, 100, 0)
+			mp, duplicates, ok := projector.projectNearest(candidates)
+			if !ok {
+				t.Fatalf("%s: expected projection", tt.name)
+			}
+			if mp.point != tt.retained || mp.source != SourceMilepost {
+				t.Errorf("%s: expected %v retained, but got %v", tt.name, tt.retained, mp.point)
+			}
+			if projector.loPrevious != mp.location.Measure {
+				t.Errorf("%s: expected window advanced to %v, but got %v", tt.name, mp.location.Measure, projector.loPrevious)
+			}
+
+			rejected := make([]orb.Point, 0, len(duplicates))
+			for _, d := range duplicates {
+				if d.rule != RuleDuplicate {
+					t.Errorf("%s: expected rule %s, but got %s", tt.name, RuleDuplicate, d.rule)
+				}
+				rejected = append(rejected, d.milepost.point)
+			}
+			if !reflect.DeepEqual(rejected, tt.rejected) {
+				t.Errorf("%s: expected %v rejected, but got %v", tt.name, tt.rejected, rejected)
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Detection and rejection of outlier mileposts prior to calibration.
+
+package main
+
+import (
+	"fmt"
+	"geofurlong/pkg/geocode"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	RuleMaxOffset   = "max_offset"   // Milepost too far from the centre-line.
+	RuleMonotonic   = "monotonic"    // Milepost linear offset does not increase with mileage.
+	RuleQmDeviation = "qm_deviation" // Quarter mile lengths either side of the milepost deviate excessively.
+	RuleDuplicate   = "duplicate"    // Another milepost of the same mileage lies nearer the centre-line.
+)
+
+// MilepostRules represents the configurable rules for rejecting mileposts from calibration.
+type MilepostRules struct {
+	MaxOffset      float64 // Maximum perpendicular distance (metres) from the centre-line, zero for no limit.
+	Monotonic      bool    // Reject mileposts whose linear offset does not increase with mileage.
+	MaxQmDeviation float64 // Maximum deviation (yards) of the normalised quarter mile length from 440 yards, zero for no limit.
+}
+
+// Milepost represents a milepost projected onto the ELR centre-line.
+type Milepost struct {
+	ty       int                  // Total yards.
+	point    orb.Point            // Surveyed position, Easting / Northing (metres).
+	location geocode.LineLocation // Projection onto the centre-line.
+	ground   float64              // Ground distance along the centre-line (metres).
+	method   string               // Projection method used to locate the milepost on the centre-line.
+	source   string               // Provenance of the surveyed position.
+	priority int                  // Priority of the surveyed position over others at the same mileage.
+}
+
+// MilepostRejection represents a milepost rejected from calibration, with the rule applied.
+type MilepostRejection struct {
+	milepost Milepost // Rejected milepost.
+	rule     string   // Rule by which the milepost was rejected.
+	detail   string   // Description of the measured value against the rule limit.
+}
+
+// readMilepostRules returns the milepost rejection rules from the configuration, with absent rules disabled.
+func readMilepostRules(cfg GeofurlongConfig) (MilepostRules, error) {
+	var (
+		rules MilepostRules
+		err   error
+	)
+
+	if value, ok := cfg["calib_max_offset_m"]; ok {
+		if rules.MaxOffset, err = strconv.ParseFloat(value, 64); err != nil {
+			return MilepostRules{}, fmt.Errorf("invalid calib_max_offset_m: %w", err)
+		}
+	}
+
+	if value, ok := cfg["calib_monotonic"]; ok {
+		if rules.Monotonic, err = strconv.ParseBool(value); err != nil {
+			return MilepostRules{}, fmt.Errorf("invalid calib_monotonic: %w", err)
+		}
+	}
+
+	if value, ok := cfg["calib_max_qm_deviation_y"]; ok {
+		if rules.MaxQmDeviation, err = strconv.ParseFloat(value, 64); err != nil {
+			return MilepostRules{}, fmt.Errorf("invalid calib_max_qm_deviation_y: %w", err)
+		}
+	}
+
+	return rules, nil
+}
+
+// filterMileposts applies the rules to the mileposts (in order of increasing mileage), returning the mileposts
+// retained for calibration and those rejected. Rules are applied in turn: offset, monotonic, then quarter mile deviation.
+func filterMileposts(mileposts []Milepost, rules MilepostRules) ([]Milepost, []MilepostRejection) {
+	kept := make([]Milepost, 0, len(mileposts))
+	rejections := make([]MilepostRejection, 0)
+
+	for _, mp := range mileposts {
+		if rules.MaxOffset > 0 && mp.location.Distance > rules.MaxOffset {
+			rejections = append(rejections, MilepostRejection{mp, RuleMaxOffset,
+				fmt.Sprintf("offset %.1fm exceeds %.1fm", mp.location.Distance, rules.MaxOffset)})
+			continue
+		}
+		kept = append(kept, mp)
+	}
+
+	if rules.Monotonic {
+		var rejected []MilepostRejection
+		kept, rejected = rejectNonMonotonic(kept)
+		rejections = append(rejections, rejected...)
+	}
+
+	if rules.MaxQmDeviation > 0 {
+		var rejected []MilepostRejection
+		kept, rejected = rejectQmDeviation(kept, rules.MaxQmDeviation)
+		rejections = append(rejections, rejected...)
+	}
+
+	sort.SliceStable(rejections, func(i, j int) bool { return rejections[i].milepost.ty < rejections[j].milepost.ty })
+	return kept, rejections
+}
+
+// rejectNonMonotonic retains the longest run (not necessarily contiguous) of mileposts with strictly increasing
+// linear offset, so that the fewest mileposts are rejected. Of equally long runs, that whose ground distances
+// best agree with the mileage differences is retained.
+func rejectNonMonotonic(mileposts []Milepost) ([]Milepost, []MilepostRejection) {
+	length := make([]int, len(mileposts))   // Length of the best run ending at each milepost.
+	cost := make([]float64, len(mileposts)) // Total disagreement (metres) of the best run ending at each milepost.
+	previous := make([]int, len(mileposts)) // Index of the preceding milepost in the best run ending at each milepost.
+	last := -1                              // Index of the milepost ending the best run overall.
+
+	for i, mp := range mileposts {
+		length[i], previous[i] = 1, -1
+		for j := range i {
+			if mileposts[j].location.Measure >= mp.location.Measure {
+				continue
+			}
+			c := cost[j] + math.Abs((mp.ground-mileposts[j].ground)-float64(mp.ty-mileposts[j].ty)*geocode.YardsToMetres)
+			if length[j]+1 > length[i] || (length[j]+1 == length[i] && c < cost[i]) {
+				length[i], cost[i], previous[i] = length[j]+1, c, j
+			}
+		}
+		if last < 0 || length[i] > length[last] || (length[i] == length[last] && cost[i] < cost[last]) {
+			last = i
+		}
+	}
+
+	keep := make([]bool, len(mileposts))
+	for i := last; i >= 0; i = previous[i] {
+		keep[i] = true
+	}
+
+	kept := make([]Milepost, 0, len(mileposts))
+	rejections := make([]MilepostRejection, 0)
+	for i, mp := range mileposts {
+		if keep[i] {
+			kept = append(kept, mp)
+			continue
+		}
+		rejections = append(rejections, MilepostRejection{mp, RuleMonotonic,
+			fmt.Sprintf("linear offset %.1fm out of sequence", mp.location.Measure)})
+	}
+
+	return kept, rejections
+}
+
+// qmDeviation returns the deviation (yards) of the normalised quarter mile length between two mileposts from 440 yards.
+func qmDeviation(from, to Milepost) float64 {
+	qmNormalised := (geocode.QuarterMileYards / float64(to.ty-from.ty)) * ((to.ground - from.ground) / geocode.YardsToMetres)
+	return math.Abs(qmNormalised - geocode.QuarterMileYards)
+}
+
+// rejectQmDeviation repeatedly rejects the intermediate milepost with the greatest deviation, where the quarter
+// mile lengths either side of it both exceed the maximum deviation, until no such milepost remains.
+// A single misplaced milepost distorts both adjacent lengths, whereas a genuinely long or short segment does not.
+func rejectQmDeviation(mileposts []Milepost, maxDeviation float64) ([]Milepost, []MilepostRejection) {
+	kept := append([]Milepost(nil), mileposts...)
+	rejections := make([]MilepostRejection, 0)
+
+	for len(kept) > 2 { // An intermediate milepost requires neighbours either side.
+		worst, worstDeviation := -1, 0.0
+		for i := 1; i < len(kept)-1; i++ {
+			before, after := qmDeviation(kept[i-1], kept[i]), qmDeviation(kept[i], kept[i+1])
+			if deviation := min(before, after); deviation > maxDeviation && deviation > worstDeviation {
+				worst, worstDeviation = i, deviation
+			}
+		}
+
+		if worst < 0 {
+			break
+		}
+
+		rejections = append(rejections, MilepostRejection{kept[worst], RuleQmDeviation,
+			fmt.Sprintf("quarter mile deviation %.1fy exceeds %.1fy", worstDeviation, maxDeviation)})
+		kept = append(kept[:worst], kept[worst+1:]...)
+	}
+
+	return kept, rejections
+}
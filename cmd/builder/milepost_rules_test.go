@@ -0,0 +1,140 @@
+package main
+
+import (
+	"geofurlong/pkg/geocode"
+	"reflect"
+	"testing"
+)
+
+// testMilepost returns a milepost at the given mileage, linear offset and perpendicular distance from the centre-line.
+func testMilepost(ty int, measure, distance float64) Milepost {
+	return Milepost{
+		ty:       ty,
+		location: geocode.LineLocation{Measure: measure, Distance: distance},
+		ground:   measure,
+	}
+}
+
+// quarterMile is the length of a quarter mile (metres).
+const quarterMile = geocode.QuarterMileYards * geocode.YardsToMetres
+
+func TestFilterMileposts(t *testing.T) {
+	rules := MilepostRules{MaxOffset: 50, Monotonic: true, MaxQmDeviation: 110}
+
+	tests := []struct {
+		name      string
+		mileposts []Milepost
+		keptTy    []int
+		rejected  map[int]string
+	}{
+		{
+			name: "all good",
+			mileposts: []Milepost{
+				testMilepost(0, 0, 5),
+				testMilepost(440, quarterMile, 5),
+				testMilepost(880, 2*quarterMile, 5),
+			},
+			keptTy:   []int{0, 440, 880},
+			rejected: map[int]string{},
+		},
+		{
+			name: "far from centre-line",
+			mileposts: []Milepost{
+				testMilepost(0, 0, 5),
+				testMilepost(440, quarterMile, 500),
+				testMilepost(880, 2*quarterMile, 5),
+			},
+			keptTy:   []int{0, 880},
+			rejected: map[int]string{440: RuleMaxOffset},
+		},
+		{
+			name: "out of sequence",
+			mileposts: []Milepost{
+				testMilepost(0, 0, 5),
+				testMilepost(440, quarterMile, 5),
+				testMilepost(880, 10, 5),
+				testMilepost(1_320, 3*quarterMile, 5),
+				testMilepost(1_760, 4*quarterMile, 5),
+			},
+			keptTy:   []int{0, 440, 1_320, 1_760},
+			rejected: map[int]string{880: RuleMonotonic},
+		},
+		{
+			name: "misplaced along the line",
+			mileposts: []Milepost{
+				testMilepost(0, 0, 5),
+				testMilepost(440, quarterMile, 5),
+				testMilepost(880, 2.5*quarterMile, 5),
+				testMilepost(1_320, 3*quarterMile, 5),
+				testMilepost(1_760, 4*quarterMile, 5),
+			},
+			keptTy:   []int{0, 440, 1_320, 1_760},
+			rejected: map[int]string{880: RuleQmDeviation},
+		},
+		{
+			name: "genuinely long quarter mile",
+			mileposts: []Milepost{
+				testMilepost(0, 0, 5),
+				testMilepost(440, 2*quarterMile, 5),
+				testMilepost(880, 3*quarterMile, 5),
+			},
+			keptTy:   []int{0, 440, 880},
+			rejected: map[int]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		kept, rejections := filterMileposts(tt.mileposts, rules)
+
+		keptTy := make([]int, 0, len(kept))
+		for _, mp := range kept {
+			keptTy = append(keptTy, mp.ty)
+		}
+		if !reflect.DeepEqual(keptTy, tt.keptTy) {
+			t.Errorf("%s: expected kept %v, but got %v", tt.name, tt.keptTy, keptTy)
+		}
+
+		rejected := make(map[int]string, len(rejections))
+		for _, r := range rejections {
+			rejected[r.milepost.ty] = r.rule
+		}
+		if !reflect.DeepEqual(rejected, tt.rejected) {
+			t.Errorf("%s: expected rejected %v, but got %v", tt.name, tt.rejected, rejected)
+		}
+	}
+}
+
+func TestFilterMilepostsDisabled(t *testing.T) {
+	mileposts := []Milepost{
+		testMilepost(0, 0, 500),
+		testMilepost(440, 2.5*quarterMile, 5),
+		testMilepost(880, 10, 5),
+	}
+
+	kept, rejections := filterMileposts(mileposts, MilepostRules{})
+	if len(kept) != len(mileposts) || len(rejections) != 0 {
+		t.Errorf("Expected all %d mileposts kept with rules disabled, but got %d kept, %d rejected",
+			len(mileposts), len(kept), len(rejections))
+	}
+}
+
+func TestReadMilepostRules(t *testing.T) {
+	cfg := GeofurlongConfig{"calib_max_offset_m": "75", "calib_monotonic": "true", "calib_max_qm_deviation_y": "110"}
+	rules, err := readMilepostRules(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := MilepostRules{MaxOffset: 75, Monotonic: true, MaxQmDeviation: 110}
+	if rules != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, rules)
+	}
+
+	if rules, err := readMilepostRules(GeofurlongConfig{}); err != nil || rules != (MilepostRules{}) {
+		t.Errorf("Expected rules disabled when absent, but got %+v, %v", rules, err)
+	}
+
+	if _, err := readMilepostRules(GeofurlongConfig{"calib_max_offset_m": "far"}); err == nil {
+		t.Error("Expected error for invalid calib_max_offset_m")
+	}
+}
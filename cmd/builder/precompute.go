@@ -22,16 +22,17 @@ func precompute(cfg GeofurlongConfig, resolution int) { //
 	}
 
 	gc, err := geocode.NewGeocoder(gcCfg)
-	geocode.Check(err)
+	check(err)
 
 	// Set up projection conversion from OSGB projected (EPSG:27700) to geographic longitude / latitude (EPSG:4326).
-	pj := geocode.OSGBToLonLat()
+	rp, err := geocode.NewReprojector(geocode.GeographicCRS)
+	check(err)
 
 	file, err := os.Create(fmt.Sprintf("%s/geofurlong_precomputed_%.4dy.csv", cfg["precompute_dir"], resolution))
-	geocode.Check(err)
+	check(err)
 	defer file.Close()
 
-	fmt.Fprintln(file, "elr,total_yards,mileage,easting,northing,longitude,latitude,osgr,accuracy")
+	fmt.Fprintln(file, "elr,total_yards,mileage,easting,northing,longitude,latitude,osgr,accuracy,grade")
 
 	// buffer 1,000 records before printing to output file to improve performance.
 	const BatchBufferLen = 1_000
@@ -52,15 +53,16 @@ func precompute(cfg GeofurlongConfig, resolution int) { //
 			}
 
 			pt, err := gc.Point(elr, ty)
-			geocode.Check(err)
+			check(err)
 
 			osgr := geocode.PointToOSGR(pt.Point)
-			lonLat := geocode.Reproject(pt.Point, pj)
+			lonLat, err := rp.Forward(pt.Point)
+			check(err)
 
 			// 6 decimal places for latitude / longitude is approximately 0.11 metre precision,
 			// notionally equivalent to the 0.1 metre precision of the OSGB Easting / Northing.
 			// Linear accuracy is rounded to nearest metre.
-			buffer.WriteString(fmt.Sprintf("%s,%d,%s,%.1f,%.1f,%.6f,%.6f,%s,%d\n",
+			buffer.WriteString(fmt.Sprintf("%s,%d,%s,%.1f,%.1f,%.6f,%.6f,%s,%d,%s\n",
 				elr,
 				ty,                                     // Total yards.
 				geocode.FmtTotalYards(ty, prop.Metric), // Formatted mileage.
@@ -69,7 +71,8 @@ func precompute(cfg GeofurlongConfig, resolution int) { //
 				lonLat.X(),                             // Longitude (decimal degrees).
 				lonLat.Y(),                             // Latitude (decimal degrees).
 				osgr,                                   // Ordnance Survey Grid Reference.
-				int(pt.Accuracy+0.5)))                  // Railway linear accuracy (metres).
+				int(pt.Accuracy+0.5),                   // Railway linear accuracy (metres).
+				pt.Grade))                              // Calibration grade.
 
 			count++
 			if count >= BatchBufferLen {
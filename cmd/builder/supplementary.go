@@ -0,0 +1,234 @@
+// Supplementary and override calibration points, such as locally surveyed milepost positions and anchor points.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geofurlong/pkg/geocode"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	SourceMilepost        = "milepost"      // Milepost database.
+	SourceELRExtent       = "elr_extent"    // Quasi-milepost at the reported extent of the ELR.
+	SourceSupplementary   = "supplementary" // Supplementary file, where the point gives no source.
+	milepostPriority      = 0               // Priority of mileposts from the milepost database.
+	supplementaryPriority = 1               // Priority of supplementary points, where the point gives no priority.
+)
+
+// gridExtent is the extent of the National Grid, within which supplementary points must lie.
+var gridExtent = orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{700_000, 1_300_000}}
+
+// SurveyedPoint represents a surveyed position at a mileage on an ELR, prior to projection onto the centre-line.
+type SurveyedPoint struct {
+	ty       int       // Total yards.
+	point    orb.Point // Surveyed position, Easting / Northing (metres).
+	source   string    // Provenance of the position.
+	priority int       // Priority over other positions at the same mileage, highest taking precedence.
+}
+
+// readSupplementary reads the supplementary calibration points, by ELR, from the CSV or GeoJSON file.
+// The file name may be empty, for no supplementary points.
+func readSupplementary(fn string) (map[string][]SurveyedPoint, error) {
+	if fn == "" {
+		return map[string][]SurveyedPoint{}, nil
+	}
+
+	file, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".csv":
+		return readSupplementaryCSV(file)
+	case ".geojson", ".json":
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		return readSupplementaryGeoJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported supplementary calibration file type: %s", fn)
+	}
+}
+
+// readSupplementaryCSV reads supplementary calibration points from CSV with a header row naming the columns
+// elr, mileage, easting and northing, and optionally source and priority (in any order).
+func readSupplementaryCSV(r io.Reader) (map[string][]SurveyedPoint, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supplementary header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"elr", "mileage", "easting", "northing"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("supplementary file has no %s column", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	points := make(map[string][]SurveyedPoint)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read supplementary record: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		easting, errE := strconv.ParseFloat(field(record, "easting"), 64)
+		northing, errN := strconv.ParseFloat(field(record, "northing"), 64)
+		if err := errors.Join(errE, errN); err != nil {
+			return nil, fmt.Errorf("line %d: invalid easting / northing: %w", line, err)
+		}
+
+		elr, sp, err := newSurveyedPoint(field(record, "elr"), field(record, "mileage"), orb.Point{easting, northing},
+			field(record, "source"), field(record, "priority"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		points[elr] = append(points[elr], sp)
+	}
+
+	return points, nil
+}
+
+// supplementaryFeatureCollection represents the subset of a GeoJSON feature collection of points used for
+// supplementary calibration points.
+type supplementaryFeatureCollection struct {
+	CRS *struct {
+		Properties struct {
+			Name string `json:"name"`
+		} `json:"properties"`
+	} `json:"crs"`
+	Features []struct {
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			ELR      string       `json:"elr"`
+			Mileage  string       `json:"mileage"`
+			Source   string       `json:"source"`
+			Priority *json.Number `json:"priority"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// readSupplementaryGeoJSON reads supplementary calibration points from a GeoJSON feature collection of points,
+// with elr, mileage, and optionally source and priority properties. Positions are Easting / Northing to the
+// National Grid (EPSG:27700), as for the CSV file; a named crs member, if given, must be EPSG:27700.
+func readSupplementaryGeoJSON(data []byte) (map[string][]SurveyedPoint, error) {
+	var fc supplementaryFeatureCollection
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("failed to read supplementary GeoJSON: %w", err)
+	}
+
+	if fc.CRS != nil && !strings.HasSuffix(fc.CRS.Properties.Name, "27700") {
+		return nil, fmt.Errorf("supplementary GeoJSON CRS %q is not %s", fc.CRS.Properties.Name, geocode.ProjectedCRS)
+	}
+
+	points := make(map[string][]SurveyedPoint)
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: geometry is not a point", i)
+		}
+
+		point := orb.Point{f.Geometry.Coordinates[0], f.Geometry.Coordinates[1]}
+		priority := ""
+		if f.Properties.Priority != nil {
+			priority = f.Properties.Priority.String()
+		}
+
+		elr, sp, err := newSurveyedPoint(f.Properties.ELR, f.Properties.Mileage, point, f.Properties.Source, priority)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		points[elr] = append(points[elr], sp)
+	}
+
+	return points, nil
+}
+
+// newSurveyedPoint returns the ELR and supplementary surveyed point from its textual attributes,
+// defaulting the source and priority if empty. The point must lie within the extent of the National Grid.
+func newSurveyedPoint(elr, mileage string, point orb.Point, source, priority string) (string, SurveyedPoint, error) {
+	elr = strings.ToUpper(elr)
+	if elr == "" {
+		return "", SurveyedPoint{}, errors.New("missing ELR")
+	}
+
+	if !gridExtent.Contains(point) {
+		return "", SurveyedPoint{}, fmt.Errorf("position %v outside the National Grid", point)
+	}
+
+	m, err := geocode.ParseMileage(mileage)
+	if err != nil {
+		return "", SurveyedPoint{}, err
+	}
+
+	if source == "" {
+		source = SourceSupplementary
+	}
+
+	sp := SurveyedPoint{ty: m.TotalYards, point: point, source: source, priority: supplementaryPriority}
+	if priority != "" {
+		if sp.priority, err = strconv.Atoi(priority); err != nil {
+			return "", SurveyedPoint{}, fmt.Errorf("invalid priority %q: %w", priority, err)
+		}
+	}
+
+	return elr, sp, nil
+}
+
+// mergeSurveyedPoints merges the supplementary points with the mileposts, returning the candidate points of each
+// mileage in order of increasing mileage. Where more than one point is at the same mileage, that of the highest
+// priority is used, with supplementary points overriding mileposts (or earlier supplementary points) of equal
+// priority. Duplicated mileposts of equal priority are all retained as candidates, to be resolved on projection.
+func mergeSurveyedPoints(mileposts, supplementary []SurveyedPoint) [][]SurveyedPoint {
+	candidates := make([]SurveyedPoint, 0, len(mileposts)+len(supplementary))
+	candidates = append(append(candidates, mileposts...), supplementary...)
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].ty < candidates[j].ty })
+
+	merged := make([][]SurveyedPoint, 0, len(candidates))
+	for _, sp := range candidates {
+		last := len(merged) - 1
+		switch {
+		case last < 0 || merged[last][0].ty != sp.ty:
+			merged = append(merged, []SurveyedPoint{sp})
+		case sp.priority > merged[last][0].priority || sp.priority == merged[last][0].priority && sp.source != SourceMilepost:
+			merged[last] = []SurveyedPoint{sp}
+		case sp.priority == merged[last][0].priority && merged[last][0].source == SourceMilepost:
+			merged[last] = append(merged[last], sp)
+		}
+	}
+
+	return merged
+}
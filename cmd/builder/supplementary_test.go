@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestReadSupplementaryCSV(t *testing.T) {
+	data := `northing,easting,ELR,mileage,source,priority
+180000.5,530000.25,abc,1m 0220y,topo survey 2023,5
+181000,531000,ABC,1.0440,,
+182000,532000,XYZ,2m 10ch,station centre,
+`
+
+	points, err := readSupplementaryCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string][]SurveyedPoint{
+		"ABC": {
+			{ty: 1_980, point: orb.Point{530_000.25, 180_000.5}, source: "topo survey 2023", priority: 5},
+			{ty: 2_200, point: orb.Point{531_000, 181_000}, source: SourceSupplementary, priority: supplementaryPriority},
+		},
+		"XYZ": {
+			{ty: 3_740, point: orb.Point{532_000, 182_000}, source: "station centre", priority: supplementaryPriority},
+		},
+	}
+
+	if !reflect.DeepEqual(points, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, points)
+	}
+}
+
+func TestReadSupplementaryCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"missing column", "elr,mileage,easting\nABC,1m 0y,530000\n"},
+		{"invalid mileage", "elr,mileage,easting,northing\nABC,one mile,530000,180000\n"},
+		{"invalid easting", "elr,mileage,easting,northing\nABC,1m 0y,east,180000\n"},
+		{"invalid priority", "elr,mileage,easting,northing,priority\nABC,1m 0y,530000,180000,high\n"},
+		{"missing ELR", "elr,mileage,easting,northing\n,1m 0y,530000,180000\n"},
+		{"outside grid", "elr,mileage,easting,northing\nABC,1m 0y,-530000,180000\n"},
+	}
+
+	for _, tt := range tests {
+		if _, err := readSupplementaryCSV(strings.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestReadSupplementaryGeoJSON(t *testing.T) {
+	data := `{"type": "FeatureCollection", "crs": {"type": "name", "properties": {"name": "urn:ogc:def:crs:EPSG::27700"}},
+		"features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [530000.25, 180000.5]},
+		"properties": {"elr": "ABC", "mileage": "1m 0220y", "source": "bridge 12", "priority": 3}}]}`
+
+	points, err := readSupplementaryGeoJSON([]byte(data))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string][]SurveyedPoint{
+		"ABC": {{ty: 1_980, point: orb.Point{530_000.25, 180_000.5}, source: "bridge 12", priority: 3}},
+	}
+	if !reflect.DeepEqual(points, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, points)
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"non-point geometry", `{"type": "FeatureCollection", "features": [{"type": "Feature",
+			"geometry": {"type": "LineString", "coordinates": [[530000, 180000], [531000, 181000]]},
+			"properties": {"elr": "ABC", "mileage": "1m 0y"}}]}`},
+		{"longitude / latitude", `{"type": "FeatureCollection", "features": [{"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [-0.12, 51.5]}, "properties": {"elr": "ABC", "mileage": "1m 0y"}}]}`},
+		{"outside grid", `{"type": "FeatureCollection", "features": [{"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [530000, 1400000]}, "properties": {"elr": "ABC", "mileage": "1m 0y"}}]}`},
+		{"geographic CRS", `{"type": "FeatureCollection", "crs": {"type": "name", "properties": {"name": "EPSG:4326"}},
+			"features": []}`},
+	}
+
+	for _, tt := range tests {
+		if _, err := readSupplementaryGeoJSON([]byte(tt.data)); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
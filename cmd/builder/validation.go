@@ -0,0 +1,81 @@
+// Leave-one-out validation of calibration, giving an empirical error of positions predicted between mileposts.
+
+package main
+
+import (
+	"geofurlong/pkg/geocode"
+	"math"
+)
+
+// MilepostResidual represents the error in the linear offset of a milepost predicted by calibrating without it.
+type MilepostResidual struct {
+	milepost  Milepost // Milepost left out of the calibration.
+	predicted float64  // Linear offset (metres) predicted by the calibration of the remaining mileposts.
+	residual  float64  // Predicted less projected linear offset (metres).
+}
+
+// ValidationStats represents the leave-one-out validation statistics of an ELR.
+type ValidationStats struct {
+	Count  int     // Number of mileposts validated.
+	RMSE   float64 // Root mean square residual (metres), zero if no mileposts.
+	MaxAbs float64 // Largest absolute residual (metres), zero if no mileposts.
+}
+
+// leaveOneOut returns the residual of each milepost (in order of increasing mileage), predicting its linear offset
+// from the calibration of the ELR, by the model, with that milepost left out.
+func leaveOneOut(ef ELRFeature, mileposts []Milepost, groundLength float64, model geocode.CalibrationModel) []MilepostResidual {
+	residuals := make([]MilepostResidual, 0, len(mileposts))
+	others := make([]Milepost, 0, len(mileposts))
+
+	for i, mp := range mileposts {
+		others = append(append(others[:0], mileposts[:i]...), mileposts[i+1:]...)
+		cs := applyCalibrationModel(calibrationPoints(ef, others, groundLength), model, ef.length)
+
+		predicted, ok := geocode.InterpolateCalibration(calibrationSegments(calibrationPointsToSegments(cs, model)), mp.ty)
+		if !ok {
+			// Nothing remains to predict from, such as the only milepost on a zero length ELR.
+			continue
+		}
+
+		residuals = append(residuals, MilepostResidual{milepost: mp, predicted: predicted, residual: predicted - mp.location.Measure})
+	}
+
+	return residuals
+}
+
+// validationStats returns the summary statistics of the leave-one-out residuals.
+func validationStats(residuals []MilepostResidual) ValidationStats {
+	stats := ValidationStats{Count: len(residuals)}
+	if stats.Count == 0 {
+		return stats
+	}
+
+	sumSquares := 0.0
+	for _, r := range residuals {
+		sumSquares += r.residual * r.residual
+		stats.MaxAbs = max(stats.MaxAbs, math.Abs(r.residual))
+	}
+	stats.RMSE = math.Sqrt(sumSquares / float64(stats.Count))
+
+	return stats
+}
+
+// calibrationSegments returns the calibration segments, as used when geocoding, of the normalised segments.
+func calibrationSegments(segments []geocode.CalibrationSegmentNormalised) []geocode.CalibrationSegment {
+	calibSegments := make([]geocode.CalibrationSegment, 0, len(segments))
+	for _, s := range segments {
+		calibSegments = append(calibSegments, geocode.CalibrationSegment{
+			TyFrom:    s.TyFrom,
+			TyTo:      s.TyTo,
+			LoFrom:    s.LoMetresFrom,
+			LoTo:      s.LoMetresTo,
+			Accuracy:  s.Accuracy,
+			Method:    s.Method,
+			Model:     s.Model,
+			SlopeFrom: s.SlopeFrom,
+			SlopeTo:   s.SlopeTo,
+		})
+	}
+
+	return calibSegments
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"geofurlong/pkg/geocode"
+	"math"
+	"testing"
+)
+
+func TestLeaveOneOut(t *testing.T) {
+	const Epsilon = 1e-6
+
+	length := 1_760 * geocode.YardsToMetres
+	ef := ELRFeature{elr: "ABC", tyFrom: 0, tyTo: 1_760, length: length}
+
+	// Mileposts every quarter mile, with that at the half mile 30 metres further along the line than its mileage.
+	mileposts := []Milepost{
+		testMilepost(440, 440*geocode.YardsToMetres, 5),
+		testMilepost(880, 880*geocode.YardsToMetres+30, 5),
+		testMilepost(1_320, 1_320*geocode.YardsToMetres, 5),
+	}
+
+	residuals := leaveOneOut(ef, mileposts, length, geocode.ModelLinear)
+
+	expected := []float64{15, -30, 15}
+	if len(residuals) != len(expected) {
+		t.Fatalf("Expected %d residuals, but got %d", len(expected), len(residuals))
+	}
+	for i, r := range residuals {
+		if r.milepost.ty != mileposts[i].ty || math.Abs(r.residual-expected[i]) > Epsilon {
+			t.Errorf("milepost %d: expected residual %v, but got %v", mileposts[i].ty, expected[i], r.residual)
+		}
+		if math.Abs(r.predicted-r.milepost.location.Measure-r.residual) > Epsilon {
+			t.Errorf("milepost %d: residual %v inconsistent with prediction %v", r.milepost.ty, r.residual, r.predicted)
+		}
+	}
+
+	stats := validationStats(residuals)
+	if stats.Count != 3 || math.Abs(stats.RMSE-math.Sqrt(450)) > Epsilon || stats.MaxAbs != 30 {
+		t.Errorf("Expected 3 residuals with RMSE %v and maximum 30, but got %+v", math.Sqrt(450), stats)
+	}
+
+	if stats := validationStats(nil); stats != (ValidationStats{}) {
+		t.Errorf("Expected empty statistics without mileposts, but got %+v", stats)
+	}
+}
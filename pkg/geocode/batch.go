@@ -0,0 +1,128 @@
+// Concurrent batch geocoding of railway mileages.
+
+package geocode
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+const batchChunkSize = 1_024 // Number of requests dispatched to a worker at a time.
+
+// Request represents a railway mileage to geocode.
+type Request struct {
+	ID  int    // Caller-defined identifier, returned unchanged in the result.
+	ELR string // ELR code.
+	Ty  int    // Mileage (as total yards).
+}
+
+// Result represents the outcome of geocoding a single request.
+type Result struct {
+	Request Request      // Originating request.
+	Point   RailwayPoint // Geographic position and linear accuracy, if successful.
+	Err     error        // Error geocoding this request, if any.
+}
+
+// workers returns the number of concurrent workers for batch geocoding.
+func (gc *Geocoder) workers() int {
+	if gc.config.Workers > 0 {
+		return gc.config.Workers
+	}
+
+	return runtime.NumCPU()
+}
+
+// PointBatch geocodes the requests concurrently, returning results in the same order as the requests.
+// Requests are processed in ELR and mileage order for cache locality. Errors for individual requests are
+// reported in their result without aborting the batch; the returned error is non-nil only if the context
+// is cancelled, in which case unprocessed results are incomplete.
+func (gc *Geocoder) PointBatch(ctx context.Context, requests []Request) ([]Result, error) {
+	results := make([]Result, len(requests))
+
+	order := make([]int, len(requests))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := requests[order[i]], requests[order[j]]
+		if a.ELR != b.ELR {
+			return a.ELR < b.ELR
+		}
+		return a.Ty < b.Ty
+	})
+
+	chunks := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < gc.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				for _, i := range chunk {
+					if ctx.Err() != nil {
+						break
+					}
+					results[i] = gc.pointResult(requests[i])
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for start := 0; start < len(order); start += batchChunkSize {
+		select {
+		case chunks <- order[start:min(start+batchChunkSize, len(order))]:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(chunks)
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+// PointStream geocodes requests received on the channel concurrently, sending each result on the returned
+// channel, in no particular order. The returned channel is closed once the requests channel is closed and
+// all requests are processed, or the context is cancelled.
+func (gc *Geocoder) PointStream(ctx context.Context, requests <-chan Request) <-chan Result {
+	results := make(chan Result, gc.workers())
+
+	var wg sync.WaitGroup
+	for w := 0; w < gc.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case r, ok := <-requests:
+					if !ok {
+						return
+					}
+					select {
+					case results <- gc.pointResult(r):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// pointResult geocodes a single request.
+func (gc *Geocoder) pointResult(r Request) Result {
+	pt, err := gc.Point(r.ELR, r.Ty)
+	return Result{Request: r, Point: pt, Err: err}
+}
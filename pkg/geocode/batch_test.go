@@ -0,0 +1,122 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// batchGeocoder returns a geocoder with two ELRs, 1 yard per metre.
+func batchGeocoder(workers int) *Geocoder {
+	gc := &Geocoder{config: GeocoderConfig{Workers: workers}}
+	gc.ELRs = map[string]ELR{
+		"AAA": {
+			TyFrom:              0,
+			TyTo:                1_000,
+			Geometry:            orb.LineString{{0, 0}, {1_000, 0}},
+			CalibrationSegments: []CalibrationSegment{{TyFrom: 0, TyTo: 1_000, LoFrom: 0, LoTo: 1_000}},
+		},
+		"BBB": {
+			TyFrom:              0,
+			TyTo:                1_000,
+			Geometry:            orb.LineString{{0, 0}, {0, 1_000}},
+			CalibrationSegments: []CalibrationSegment{{TyFrom: 0, TyTo: 1_000, LoFrom: 0, LoTo: 1_000}},
+		},
+	}
+
+	return gc
+}
+
+func TestPointBatch(t *testing.T) {
+	gc := batchGeocoder(3)
+
+	requests := make([]Request, 0, 5_000)
+	for i := 0; i < 5_000; i++ {
+		elr := "AAA"
+		if i%2 == 1 {
+			elr = "BBB"
+		}
+		requests = append(requests, Request{ID: i, ELR: elr, Ty: (i * 7) % 1_100})
+	}
+	requests = append(requests, Request{ID: -1, ELR: "ZZZ", Ty: 0})
+
+	results, err := gc.PointBatch(context.Background(), requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(requests) {
+		t.Fatalf("expected %d results, but got %d", len(requests), len(results))
+	}
+
+	for i, r := range results {
+		if r.Request != requests[i] {
+			t.Fatalf("result %d out of order: %v", i, r.Request)
+		}
+
+		want, wantErr := gc.Point(r.Request.ELR, r.Request.Ty)
+		if (wantErr == nil) != (r.Err == nil) || r.Point != want {
+			t.Errorf("result %d: expected %v (%v), but got %v (%v)", i, want, wantErr, r.Point, r.Err)
+		}
+	}
+
+	if !errors.Is(results[len(results)-1].Err, ErrUnknownELR) {
+		t.Errorf("expected ErrUnknownELR for unknown ELR, but got %v", results[len(results)-1].Err)
+	}
+}
+
+func TestPointBatchCancelled(t *testing.T) {
+	gc := batchGeocoder(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := gc.PointBatch(ctx, make([]Request, 10_000))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+}
+
+func TestPointStream(t *testing.T) {
+	gc := batchGeocoder(4)
+
+	requests := make(chan Request)
+	go func() {
+		defer close(requests)
+		for i := 0; i <= 1_000; i++ {
+			requests <- Request{ID: i, ELR: "BBB", Ty: i}
+		}
+	}()
+
+	seen := make(map[int]bool)
+	for r := range gc.PointStream(context.Background(), requests) {
+		if r.Err != nil {
+			t.Errorf("unexpected error: %v", r.Err)
+		}
+		if r.Point.Point != (orb.Point{0, float64(r.Request.Ty)}) {
+			t.Errorf("request %v: unexpected point %v", r.Request, r.Point)
+		}
+		seen[r.Request.ID] = true
+	}
+
+	if len(seen) != 1_001 {
+		t.Errorf("expected 1001 results, but got %d", len(seen))
+	}
+}
+
+func TestPointStreamCancelled(t *testing.T) {
+	gc := batchGeocoder(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := make(chan Request) // Never closed; cancellation alone must close the results.
+	results := gc.PointStream(ctx, requests)
+
+	requests <- Request{ELR: "AAA", Ty: 1}
+	<-results
+	cancel()
+
+	for range results {
+	}
+}
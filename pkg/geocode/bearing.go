@@ -0,0 +1,80 @@
+// Direction of increasing mileage along an ELR centre-line.
+
+package geocode
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/twpayne/go-proj/v10"
+)
+
+const (
+	wgs84SemiMajorAxis  = 6_378_137.0          // WGS84 ellipsoid semi-major axis (metres).
+	wgs84Eccentricity2  = 0.006_694_379_990_14 // WGS84 ellipsoid first eccentricity squared.
+	trueBearingStepGrid = 10.0                 // Distance (metres) stepped along the grid bearing to establish the true bearing.
+	degreesPerRadian    = 180 / math.Pi        // Radians to degrees conversion factor.
+	bearingFullRotation = 360.0                // Degrees in a full rotation.
+)
+
+// RailwayBearing represents the direction of increasing mileage at a railway point.
+type RailwayBearing struct {
+	Point orb.Point // Easting / Northing to EPSG:27700 (metres).
+	Grid  float64   // Bearing of increasing mileage, clockwise from grid north (degrees).
+}
+
+// Bearing returns the grid bearing of increasing mileage at the given distance (as total yards) on the ELR,
+// taken from the ELR linestring segment that the point is interpolated upon.
+func (gc *Geocoder) Bearing(elr string, ty int) (RailwayBearing, error) {
+	m, err := gc.match(elr, ty)
+	if err != nil {
+		return RailwayBearing{}, err
+	}
+
+	distance := m.distance()
+	line := m.elr.measuredLine()
+	i, ok := line.segmentAt(distance)
+	if !ok {
+		return RailwayBearing{}, fmt.Errorf("ELR %s has no geometry to establish bearing", elr)
+	}
+
+	return RailwayBearing{
+		Point: line.pointAtExtended(distance),
+		Grid:  gridBearing(m.elr.Geometry[i], m.elr.Geometry[i+1]),
+	}, nil
+}
+
+// True returns the bearing of increasing mileage clockwise from true north (degrees), using the transformer from
+// projected OSGB36 (EPSG:27700) to geographic Longitude / Latitude (EPSG:4326) to account for grid convergence.
+func (b RailwayBearing) True(pj *proj.PJ) (float64, error) {
+	sinB, cosB := math.Sincos(b.Grid / degreesPerRadian)
+	ahead := orb.Point{b.Point.X() + trueBearingStepGrid*sinB, b.Point.Y() + trueBearingStepGrid*cosB}
+
+	lonLats, err := ReprojectMulti([]orb.Point{b.Point, ahead}, pj)
+	if err != nil {
+		return 0, err
+	}
+
+	return ellipsoidalBearing(lonLats[0], lonLats[1]), nil
+}
+
+// ellipsoidalBearing returns the bearing (degrees clockwise from true north, 0 to 360) between two nearby
+// Longitude / Latitude points, scaling by the WGS84 meridional and prime vertical radii of curvature.
+func ellipsoidalBearing(fromPoint, toPoint orb.Point) float64 {
+	lat := (fromPoint.Lat() + toPoint.Lat()) / 2 / degreesPerRadian
+	sinLat := math.Sin(lat)
+	w := 1 - wgs84Eccentricity2*sinLat*sinLat
+	meridional := wgs84SemiMajorAxis * (1 - wgs84Eccentricity2) / math.Pow(w, 1.5)
+	primeVertical := wgs84SemiMajorAxis / math.Sqrt(w)
+
+	east := (toPoint.Lon() - fromPoint.Lon()) / degreesPerRadian * primeVertical * math.Cos(lat)
+	north := (toPoint.Lat() - fromPoint.Lat()) / degreesPerRadian * meridional
+
+	bearing := math.Atan2(east, north) * degreesPerRadian
+	if bearing < 0 {
+		bearing += bearingFullRotation
+	}
+
+	return bearing
+}
@@ -0,0 +1,109 @@
+package geocode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestBearing(t *testing.T) {
+	gc := Geocoder{}
+	gc.ELRs = map[string]ELR{
+		"ABC": {
+			TyFrom:   0,
+			TyTo:     300,
+			Geometry: orb.LineString{{0, 0}, {0, 100}, {0, 100}, {100, 100}, {100, 0}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 0, TyTo: 300, LoFrom: 0, LoTo: 300},
+			},
+		},
+	}
+
+	cases := []struct {
+		ty       int
+		point    orb.Point
+		expected float64
+	}{
+		{ty: 0, point: orb.Point{0, 0}, expected: 0},
+		{ty: 50, point: orb.Point{0, 50}, expected: 0},
+		{ty: 100, point: orb.Point{0, 100}, expected: 90}, // Vertex takes the following segment, skipping the repeated point.
+		{ty: 150, point: orb.Point{50, 100}, expected: 90},
+		{ty: 250, point: orb.Point{100, 50}, expected: 180},
+		{ty: 300, point: orb.Point{100, 0}, expected: 180},
+	}
+
+	for _, c := range cases {
+		b, err := gc.Bearing("ABC", c.ty)
+		if err != nil {
+			t.Errorf("Bearing(%d) returned error: %v", c.ty, err)
+			continue
+		}
+
+		if !almostEqual(b.Grid, c.expected) || b.Point != c.point {
+			t.Errorf("Bearing(%d) = %v; want %v at %v", c.ty, b, c.expected, c.point)
+		}
+	}
+
+	if _, err := gc.Bearing("ABC", 301); err == nil {
+		t.Error("expected error for mileage beyond calibrated extent")
+	}
+}
+
+func TestGridBearing(t *testing.T) {
+	cases := []struct {
+		from     orb.Point
+		to       orb.Point
+		expected float64
+	}{
+		{from: orb.Point{0, 0}, to: orb.Point{0, 1}, expected: 0},
+		{from: orb.Point{0, 0}, to: orb.Point{1, 1}, expected: 45},
+		{from: orb.Point{0, 0}, to: orb.Point{1, 0}, expected: 90},
+		{from: orb.Point{0, 0}, to: orb.Point{0, -1}, expected: 180},
+		{from: orb.Point{0, 0}, to: orb.Point{-1, 0}, expected: 270},
+		{from: orb.Point{5, 5}, to: orb.Point{4, 6}, expected: 315},
+	}
+
+	for _, c := range cases {
+		if got := gridBearing(c.from, c.to); !almostEqual(got, c.expected) {
+			t.Errorf("gridBearing(%v, %v) = %v; want %v", c.from, c.to, got, c.expected)
+		}
+	}
+}
+
+func TestTrueBearing(t *testing.T) {
+	pj, err := OSGBToLonLat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const Epsilon = 0.01 // Degrees.
+
+	// On the central meridian (2 degrees West) of the OS National Grid, grid and true north coincide.
+	for _, grid := range []float64{0, 90, 180, 270} {
+		b := RailwayBearing{Point: orb.Point{400_000, 300_000}, Grid: grid}
+		got, err := b.True(pj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if delta := math.Mod(got-grid+540, 360) - 180; math.Abs(delta) > Epsilon {
+			t.Errorf("central meridian true bearing = %v; want %v", got, grid)
+		}
+	}
+
+	// Away from the central meridian, true bearing differs from grid bearing by the grid convergence,
+	// approximately the longitude difference multiplied by the sine of the latitude.
+	for _, place := range getTestPlaces() {
+		b := RailwayBearing{Point: orb.Point{float64(place.easting), float64(place.northing)}, Grid: 0}
+		got, err := b.True(pj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		convergence := (place.lonLat.Lon() + 2) * math.Sin(place.lonLat.Lat()/degreesPerRadian)
+		if delta := math.Mod(got-convergence+540, 360) - 180; math.Abs(delta) > 0.1 {
+			t.Errorf("%s: true bearing of grid north = %v; want approximately %v", place.name, got, convergence)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Versioned, self-validating serialised cache of ELR and calibration.
+
+package geocode
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	cacheMagic         = "geofurlong-cache" // Identifies a geocoder cache file.
+	cacheFormatVersion = 5                  // Incremented on any change to the cache layout or ELR / calibration types.
+)
+
+// cacheSource represents the identity of the production database a cache was built from.
+type cacheSource struct {
+	DataVersion string // Value of the "version" property in the production database version table.
+	DbSize      int64  // Size of the production database file (bytes).
+	DbModTime   int64  // Modification time of the production database file (Unix nanoseconds).
+}
+
+// cacheHeader represents the header preceding the serialised ELR payload in the cache file.
+type cacheHeader struct {
+	Magic         string            // Always cacheMagic.
+	FormatVersion int               // Cache format version the file was written with.
+	Source        cacheSource       // Identity of the production database the cache was built from.
+	Checksum      [sha256.Size]byte // SHA-256 hash of the serialised ELR payload.
+}
+
+// CacheFormat represents the on-disk layout of the serialised cache.
+type CacheFormat int
+
+const (
+	CacheGob    CacheFormat = iota // Gob encoded, decoded onto the heap on load.
+	CacheBinary                    // Flat little-endian binary, memory-mapped and read in place where supported.
+)
+
+// loadELRs loads the ELR cache, (re)building it from the production database if absent, invalid or stale.
+// If the production database is unavailable, a valid cache is used as is.
+func (gc *Geocoder) loadELRs() error {
+	source, sourceErr := gc.readCacheSource()
+	cached, cacheErr := gc.openCache(func(cached cacheSource) bool { return sourceErr != nil || cached == source })
+
+	switch {
+	case cacheErr == nil && (sourceErr != nil || cached == source):
+		return nil
+	case sourceErr != nil && errors.Is(cacheErr, os.ErrNotExist):
+		return fmt.Errorf("failed to import data: %w", sourceErr)
+	case sourceErr != nil:
+		return fmt.Errorf("failed to deserialise cache: %w", cacheErr)
+	case cacheErr == nil:
+		log.Printf("Rebuilding stale cache, as production database has changed (version %q, was %q)",
+			source.DataVersion, cached.DataVersion)
+	case !errors.Is(cacheErr, os.ErrNotExist):
+		log.Printf("Rebuilding invalid cache: %v", cacheErr)
+	default:
+		log.Printf("Building cache from production database")
+	}
+
+	// Release any stale mapped cache before rebuilding, leaving the Geocoder open.
+	if gc.unmap != nil {
+		if err := gc.unmap(); err != nil {
+			return err
+		}
+		gc.unmap, gc.ELRs = nil, nil
+	}
+
+	if err := gc.buildCache(); err != nil {
+		return fmt.Errorf("failed to import data: %w", err)
+	}
+	if err := gc.writeCache(source); err != nil {
+		return fmt.Errorf("failed to serialise cache: %w", err)
+	}
+
+	return nil
+}
+
+// openCache returns the identity of the production database the cache was built from, loading the ELRs from
+// the cache in the configured format only if fresh for that production database, so a stale cache is not decoded.
+func (gc *Geocoder) openCache(fresh func(cacheSource) bool) (cacheSource, error) {
+	if gc.config.CacheFormat == CacheBinary {
+		return gc.openBinaryCache(fresh)
+	}
+
+	file, err := os.Open(gc.config.CacheFn)
+	if err != nil {
+		return cacheSource{}, err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	header, err := readCacheHeader(decoder, gc.config.CacheFn)
+	if err != nil || !fresh(header.Source) {
+		return header.Source, err
+	}
+
+	payload, err := readCachePayload(decoder, header, gc.config.CacheFn)
+	if err != nil {
+		return cacheSource{}, err
+	}
+
+	return header.Source, gc.decodeCache(payload, gc.config.CacheFn)
+}
+
+// writeCache writes the ELR cache in the configured format.
+func (gc *Geocoder) writeCache(source cacheSource) error {
+	if gc.config.CacheFormat == CacheBinary {
+		return writeFileAtomic(gc.config.CacheFn, func(w io.Writer) error {
+			return writeBinaryCache(w, gc.ELRs, source)
+		})
+	}
+
+	return gc.serialiseCache(source)
+}
+
+// readCacheSource returns the identity of the production database.
+func (gc *Geocoder) readCacheSource() (cacheSource, error) {
+	info, err := os.Stat(gc.config.ProductionDbFn)
+	if err != nil {
+		return cacheSource{}, err
+	}
+
+	prodDb, err := sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", gc.config.ProductionDbFn))
+	if err != nil {
+		return cacheSource{}, err
+	}
+	defer prodDb.Close()
+
+	var dataVersion string
+	const versionSQL = "SELECT value FROM version WHERE property = 'version'"
+	if err := prodDb.QueryRow(versionSQL).Scan(&dataVersion); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return cacheSource{}, err
+	}
+
+	return cacheSource{DataVersion: dataVersion, DbSize: info.Size(), DbModTime: info.ModTime().UnixNano()}, nil
+}
+
+// readCache reads the cache file, returning its header and the verified ELR payload.
+func readCache(cacheFn string) (cacheHeader, []byte, error) {
+	file, err := os.Open(cacheFn)
+	if err != nil {
+		return cacheHeader{}, nil, err
+	}
+	defer file.Close()
+
+	return readCacheFrom(file, cacheFn)
+}
+
+// readCacheFrom reads the gob cache from the reader, returning its header and the verified ELR payload.
+// The cache name is used to describe errors only.
+func readCacheFrom(r io.Reader, cacheFn string) (cacheHeader, []byte, error) {
+	decoder := gob.NewDecoder(r)
+	header, err := readCacheHeader(decoder, cacheFn)
+	if err != nil {
+		return cacheHeader{}, nil, err
+	}
+
+	payload, err := readCachePayload(decoder, header, cacheFn)
+	if err != nil {
+		return cacheHeader{}, nil, err
+	}
+
+	return header, payload, nil
+}
+
+// readCacheHeader decodes and validates the gob cache header. The cache name is used to describe errors only.
+func readCacheHeader(decoder *gob.Decoder, cacheFn string) (cacheHeader, error) {
+	var header cacheHeader
+	if err := decoder.Decode(&header); err != nil || header.Magic != cacheMagic {
+		return cacheHeader{}, fmt.Errorf("%w: %s: not a geocoder cache", ErrCacheCorrupt, cacheFn)
+	}
+
+	if header.FormatVersion != cacheFormatVersion {
+		return cacheHeader{}, fmt.Errorf("%w: %s: format version %d, expected %d",
+			ErrCacheCorrupt, cacheFn, header.FormatVersion, cacheFormatVersion)
+	}
+
+	return header, nil
+}
+
+// readCachePayload decodes the ELR payload following the gob cache header, verifying it against the header
+// checksum. The cache name is used to describe errors only.
+func readCachePayload(decoder *gob.Decoder, header cacheHeader, cacheFn string) ([]byte, error) {
+	var payload []byte
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCacheCorrupt, cacheFn, err)
+	}
+
+	if sha256.Sum256(payload) != header.Checksum {
+		return nil, fmt.Errorf("%w: %s: checksum mismatch", ErrCacheCorrupt, cacheFn)
+	}
+
+	return payload, nil
+}
+
+// decodeCache decodes the verified ELR payload. The cache name is used to describe errors only.
+func (gc *Geocoder) decodeCache(payload []byte, cacheFn string) error {
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&gc.ELRs); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCacheCorrupt, cacheFn, err)
+	}
+
+	return nil
+}
+
+// serialiseCache writes the ELR cache to disk in gob format.
+func (gc *Geocoder) serialiseCache(source cacheSource) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(gc.ELRs); err != nil {
+		return err
+	}
+
+	header := cacheHeader{
+		Magic:         cacheMagic,
+		FormatVersion: cacheFormatVersion,
+		Source:        source,
+		Checksum:      sha256.Sum256(payload.Bytes()),
+	}
+
+	return writeFileAtomic(gc.config.CacheFn, func(w io.Writer) error {
+		encoder := gob.NewEncoder(w)
+		if err := encoder.Encode(header); err != nil {
+			return err
+		}
+		return encoder.Encode(payload.Bytes())
+	})
+}
+
+// writeFileAtomic writes a file via a temporary file which is then renamed,
+// so concurrent readers never observe a partially written file.
+func writeFileAtomic(fn string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // No-op once renamed.
+
+	buffered := bufio.NewWriter(file)
+	if err := write(buffered); err != nil {
+		file.Close()
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	// Temporary files are created private, so are made readable by other users of the cache before renaming.
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), fn)
+}
@@ -0,0 +1,382 @@
+// Flat little-endian binary cache of ELR and calibration, read in place from a memory-mapped file.
+
+package geocode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"unsafe"
+
+	"github.com/paulmach/orb"
+)
+
+// Binary cache layout, with all values little-endian and all sections 8-byte aligned:
+//
+//	header       binaryHeaderSize bytes
+//	ELR index    binaryELRSize bytes per ELR, in alphabetical order
+//	coordinates  16 bytes (float64 X, Y) per point, for all ELR geometries in index order
+//	measures     8 bytes (float64) per point, cumulative distance along each ELR geometry
+//	calibration  binaryCalibSize bytes per segment, for all ELRs in index order
+//
+// The checksum in the header is the SHA-256 hash of the header (with a zero checksum), the ELR index and the
+// calibration, which are decoded on open. Coordinates and measures, read in place, are not hashed so that opening
+// does not read every page of the file; they are covered by the size consistency check only.
+const (
+	binaryCacheMagic         = "GFCACHE\x00" // Identifies a binary geocoder cache file.
+	binaryCacheFormatVersion = 6             // Incremented on any change to the binary layout.
+	binaryHeaderSize         = 112           // Bytes in the header.
+	binaryELRSize            = 48            // Bytes per ELR index record.
+	binaryPointSize          = 16            // Bytes per coordinate pair.
+	binaryMeasureSize        = 8             // Bytes per cumulative distance.
+	binaryCalibSize          = 56            // Bytes per calibration segment record.
+	binaryCodeLen            = 8             // Maximum bytes in an ELR code.
+	binaryVersionLen         = 32            // Maximum bytes in the production database data version.
+	binaryMetricFlag         = 1             // ELR flags bit for kilometre reporting.
+)
+
+// binaryHeader represents the fixed-size header of the binary cache.
+type binaryHeader struct {
+	Magic         [8]byte                // Always binaryCacheMagic.
+	FormatVersion uint32                 // Binary format version the file was written with.
+	ELRCount      uint32                 // Number of ELR index records.
+	PointCount    uint64                 // Number of coordinate pairs.
+	CalibCount    uint64                 // Number of calibration segment records.
+	DbSize        int64                  // Size of the production database file (bytes).
+	DbModTime     int64                  // Modification time of the production database file (Unix nanoseconds).
+	DataVersion   [binaryVersionLen]byte // Production database data version, zero padded.
+	Checksum      [sha256.Size]byte      // SHA-256 hash of the header, ELR index and calibration.
+}
+
+// binaryELR represents an ELR index record of the binary cache.
+type binaryELR struct {
+	Code       [binaryCodeLen]byte // ELR code, zero padded.
+	TyFrom     int32               // Total yards from.
+	TyTo       int32               // Total yards to.
+	ShapeLen   float64             // Geometry linestring length (metres).
+	PointStart uint32              // Index of the first coordinate pair.
+	PointCount uint32              // Number of coordinate pairs.
+	CalibStart uint32              // Index of the first calibration segment.
+	CalibCount uint32              // Number of calibration segments.
+	Flags      uint32              // Bit flags, see binaryMetricFlag.
+	_          uint32              // Padding to 8-byte alignment.
+}
+
+// binaryCalib represents a calibration segment record of the binary cache.
+type binaryCalib struct {
+	TyFrom    int32   // Total yards from.
+	TyTo      int32   // Total yards to.
+	LoFrom    float64 // Linear offset from (metres).
+	LoTo      float64 // Linear offset to (metres).
+	Accuracy  float64 // Linear accuracy (metres).
+	SlopeFrom float64 // Rate of change of linear offset with mileage at low mileage end (metres per yard).
+	SlopeTo   float64 // Rate of change of linear offset with mileage at high mileage end (metres per yard).
+	Method    uint8   // Calibration method.
+	Model     uint8   // Calibration model.
+	Grade     uint8   // Calibration grade.
+	_         [5]byte // Padding to 8-byte alignment.
+}
+
+// hostLittleEndian reports whether the host byte order matches the cache, permitting coordinates to be read in place.
+var hostLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// writeBinaryCache writes the ELRs in binary cache layout.
+func writeBinaryCache(w io.Writer, elrs map[string]ELR, source cacheSource) error {
+	if len(source.DataVersion) > binaryVersionLen {
+		return fmt.Errorf("data version %q exceeds %d bytes", source.DataVersion, binaryVersionLen)
+	}
+
+	codes := make([]string, 0, len(elrs))
+	for elr := range elrs {
+		if len(elr) > binaryCodeLen {
+			return fmt.Errorf("ELR code %q exceeds %d bytes", elr, binaryCodeLen)
+		}
+		codes = append(codes, elr)
+	}
+	sort.Strings(codes)
+
+	var index, coords, measures, calibs bytes.Buffer
+	var pointCount, calibCount uint64
+	for _, elr := range codes {
+		e := elrs[elr]
+
+		record := binaryELR{
+			TyFrom:     int32(e.TyFrom),
+			TyTo:       int32(e.TyTo),
+			ShapeLen:   e.ShapeLen,
+			PointStart: uint32(pointCount),
+			PointCount: uint32(len(e.Geometry)),
+			CalibStart: uint32(calibCount),
+			CalibCount: uint32(len(e.CalibrationSegments)),
+		}
+		copy(record.Code[:], elr)
+		if e.Metric {
+			record.Flags |= binaryMetricFlag
+		}
+		binary.Write(&index, binary.LittleEndian, record)
+
+		binary.Write(&coords, binary.LittleEndian, []orb.Point(e.Geometry))
+		if len(e.Geometry) > 0 {
+			binary.Write(&measures, binary.LittleEndian, e.measuredLine().measures)
+		}
+		for _, c := range e.CalibrationSegments {
+			binary.Write(&calibs, binary.LittleEndian, binaryCalib{
+				TyFrom:    int32(c.TyFrom),
+				TyTo:      int32(c.TyTo),
+				LoFrom:    c.LoFrom,
+				LoTo:      c.LoTo,
+				Accuracy:  c.Accuracy,
+				SlopeFrom: c.SlopeFrom,
+				SlopeTo:   c.SlopeTo,
+				Method:    uint8(c.Method),
+				Model:     uint8(c.Model),
+				Grade:     uint8(c.Grade),
+			})
+		}
+
+		pointCount += uint64(len(e.Geometry))
+		calibCount += uint64(len(e.CalibrationSegments))
+	}
+
+	header := binaryHeader{
+		FormatVersion: binaryCacheFormatVersion,
+		ELRCount:      uint32(len(codes)),
+		PointCount:    pointCount,
+		CalibCount:    calibCount,
+		DbSize:        source.DbSize,
+		DbModTime:     source.DbModTime,
+	}
+	copy(header.Magic[:], binaryCacheMagic)
+	copy(header.DataVersion[:], source.DataVersion)
+
+	header.Checksum = binaryChecksum(header, index.Bytes(), calibs.Bytes())
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	for _, section := range []*bytes.Buffer{&index, &coords, &measures, &calibs} {
+		if _, err := section.WriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// binaryChecksum returns the SHA-256 hash of the header, excluding its checksum, the ELR index and the calibration.
+func binaryChecksum(header binaryHeader, index, calibs []byte) [sha256.Size]byte {
+	header.Checksum = [sha256.Size]byte{}
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, header)
+	hash.Write(index)
+	hash.Write(calibs)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// openBinaryCache memory-maps the binary cache and, if fresh for the production database it was built from,
+// loads the ELRs with geometries read in place.
+func (gc *Geocoder) openBinaryCache(fresh func(cacheSource) bool) (cacheSource, error) {
+	data, unmap, err := mapFile(gc.config.CacheFn)
+	if err != nil {
+		return cacheSource{}, err
+	}
+
+	header, err := readBinaryHeader(data)
+	if err != nil {
+		unmap()
+		return cacheSource{}, fmt.Errorf("%w: %s: %v", ErrCacheCorrupt, gc.config.CacheFn, err)
+	}
+	if !fresh(header.source()) {
+		return header.source(), unmap()
+	}
+
+	source, elrs, err := readBinaryCache(data)
+	if err != nil {
+		unmap()
+		return cacheSource{}, fmt.Errorf("%w: %s: %v", ErrCacheCorrupt, gc.config.CacheFn, err)
+	}
+
+	gc.ELRs, gc.unmap = elrs, unmap
+	return source, nil
+}
+
+// readAligned reads all of the reader into 8-byte aligned memory, as required to read coordinates in place.
+func readAligned(r io.Reader) ([]byte, error) {
+	contents, err := io.ReadAll(r)
+	if err != nil || len(contents) == 0 {
+		return contents, err
+	}
+
+	aligned := make([]uint64, (len(contents)+7)/8)
+	data := unsafe.Slice((*byte)(unsafe.Pointer(&aligned[0])), len(contents))
+	copy(data, contents)
+	return data, nil
+}
+
+// readBinaryCache validates the binary cache and returns its ELRs. If the host is little-endian, geometries
+// reference the data directly, which must therefore be 8-byte aligned and remain valid while the ELRs are in use.
+func readBinaryCache(data []byte) (cacheSource, map[string]ELR, error) {
+	header, err := readBinaryHeader(data)
+	if err != nil {
+		return cacheSource{}, nil, err
+	}
+
+	indexStart := uint64(binaryHeaderSize)
+	coordsStart := indexStart + uint64(header.ELRCount)*binaryELRSize
+	measuresStart := coordsStart + header.PointCount*binaryPointSize
+	calibStart := measuresStart + header.PointCount*binaryMeasureSize
+	end := calibStart + header.CalibCount*binaryCalibSize
+
+	if binaryChecksum(header, data[indexStart:coordsStart], data[calibStart:end]) != header.Checksum {
+		return cacheSource{}, nil, fmt.Errorf("checksum mismatch")
+	}
+
+	var (
+		points   []orb.Point
+		measures []float64
+	)
+	if hostLittleEndian && header.PointCount > 0 {
+		points = unsafe.Slice((*orb.Point)(unsafe.Pointer(&data[coordsStart])), header.PointCount)
+		measures = unsafe.Slice((*float64)(unsafe.Pointer(&data[measuresStart])), header.PointCount)
+	} else {
+		points = make([]orb.Point, header.PointCount)
+		measures = make([]float64, header.PointCount)
+		for i := range points {
+			offset := coordsStart + uint64(i)*binaryPointSize
+			points[i] = orb.Point{float64At(data, offset), float64At(data, offset+8)}
+			measures[i] = float64At(data, measuresStart+uint64(i)*binaryMeasureSize)
+		}
+	}
+
+	elrs := make(map[string]ELR, header.ELRCount)
+	for i := uint64(0); i < uint64(header.ELRCount); i++ {
+		record := decodeBinaryELR(data[indexStart+i*binaryELRSize:])
+
+		if uint64(record.PointStart)+uint64(record.PointCount) > header.PointCount ||
+			uint64(record.CalibStart)+uint64(record.CalibCount) > header.CalibCount {
+			return cacheSource{}, nil, fmt.Errorf("ELR record %d out of range", i)
+		}
+
+		e := ELR{
+			TyFrom:   int(record.TyFrom),
+			TyTo:     int(record.TyTo),
+			ShapeLen: record.ShapeLen,
+			Metric:   record.Flags&binaryMetricFlag != 0,
+		}
+
+		if record.PointCount > 0 {
+			// Capacity is limited so that appending to a geometry never writes into the mapped file.
+			start, end := record.PointStart, record.PointStart+record.PointCount
+			e.Geometry = orb.LineString(points[start:end:end])
+			e.Measures = measures[start:end:end]
+		}
+
+		if record.CalibCount > 0 {
+			e.CalibrationSegments = make([]CalibrationSegment, record.CalibCount)
+			for j := range e.CalibrationSegments {
+				c := decodeBinaryCalib(data[calibStart+(uint64(record.CalibStart)+uint64(j))*binaryCalibSize:])
+				e.CalibrationSegments[j] = CalibrationSegment{
+					TyFrom:    int(c.TyFrom),
+					TyTo:      int(c.TyTo),
+					LoFrom:    c.LoFrom,
+					LoTo:      c.LoTo,
+					Accuracy:  c.Accuracy,
+					SlopeFrom: c.SlopeFrom,
+					SlopeTo:   c.SlopeTo,
+					Method:    CalibrationMethod(c.Method),
+					Model:     CalibrationModel(c.Model),
+					Grade:     CalibrationGrade(c.Grade),
+				}
+			}
+		}
+
+		elrs[string(bytes.TrimRight(record.Code[:], "\x00"))] = e
+	}
+
+	return header.source(), elrs, nil
+}
+
+// readBinaryHeader validates the header of the binary cache against its size, without reading the remainder.
+func readBinaryHeader(data []byte) (binaryHeader, error) {
+	if len(data) < binaryHeaderSize {
+		return binaryHeader{}, fmt.Errorf("truncated header")
+	}
+
+	var header binaryHeader
+	if err := binary.Read(bytes.NewReader(data[:binaryHeaderSize]), binary.LittleEndian, &header); err != nil {
+		return binaryHeader{}, err
+	}
+
+	if string(header.Magic[:]) != binaryCacheMagic {
+		return binaryHeader{}, fmt.Errorf("not a binary geocoder cache")
+	}
+
+	if header.FormatVersion != binaryCacheFormatVersion {
+		return binaryHeader{}, fmt.Errorf("format version %d, expected %d", header.FormatVersion, binaryCacheFormatVersion)
+	}
+
+	if header.PointCount > math.MaxUint32 || header.CalibCount > math.MaxUint32 {
+		return binaryHeader{}, fmt.Errorf("counts inconsistent with header")
+	}
+
+	size := uint64(binaryHeaderSize) + uint64(header.ELRCount)*binaryELRSize +
+		header.PointCount*(binaryPointSize+binaryMeasureSize) + header.CalibCount*binaryCalibSize
+	if size != uint64(len(data)) {
+		return binaryHeader{}, fmt.Errorf("size %d inconsistent with header", len(data))
+	}
+
+	return header, nil
+}
+
+// source returns the identity of the production database the binary cache was built from.
+func (h binaryHeader) source() cacheSource {
+	return cacheSource{
+		DataVersion: string(bytes.TrimRight(h.DataVersion[:], "\x00")),
+		DbSize:      h.DbSize,
+		DbModTime:   h.DbModTime,
+	}
+}
+
+// decodeBinaryELR decodes the ELR index record at the start of the data.
+func decodeBinaryELR(data []byte) binaryELR {
+	var record binaryELR
+	copy(record.Code[:], data)
+	record.TyFrom = int32(binary.LittleEndian.Uint32(data[8:]))
+	record.TyTo = int32(binary.LittleEndian.Uint32(data[12:]))
+	record.ShapeLen = float64At(data, 16)
+	record.PointStart = binary.LittleEndian.Uint32(data[24:])
+	record.PointCount = binary.LittleEndian.Uint32(data[28:])
+	record.CalibStart = binary.LittleEndian.Uint32(data[32:])
+	record.CalibCount = binary.LittleEndian.Uint32(data[36:])
+	record.Flags = binary.LittleEndian.Uint32(data[40:])
+	return record
+}
+
+// decodeBinaryCalib decodes the calibration segment record at the start of the data.
+func decodeBinaryCalib(data []byte) binaryCalib {
+	return binaryCalib{
+		TyFrom:    int32(binary.LittleEndian.Uint32(data[0:])),
+		TyTo:      int32(binary.LittleEndian.Uint32(data[4:])),
+		LoFrom:    float64At(data, 8),
+		LoTo:      float64At(data, 16),
+		Accuracy:  float64At(data, 24),
+		SlopeFrom: float64At(data, 32),
+		SlopeTo:   float64At(data, 40),
+		Method:    data[48],
+		Model:     data[49],
+		Grade:     data[50],
+	}
+}
+
+// float64At decodes the little-endian float64 at the byte offset of the data.
+func float64At(data []byte, offset uint64) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+}
@@ -0,0 +1,181 @@
+package geocode
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// alignedCopy returns a copy of the data in 8-byte aligned memory, as provided by mapFile.
+func alignedCopy(data []byte) []byte {
+	aligned, _ := readAligned(bytes.NewReader(data))
+	return aligned
+}
+
+func TestBinaryCacheRoundTrip(t *testing.T) {
+	elrs := map[string]ELR{
+		"ABC1": {
+			TyFrom:   -220,
+			TyTo:     1_760,
+			ShapeLen: 1_650.5,
+			Geometry: orb.LineString{{400_000.1, 300_000.2}, {401_000, 300_500}, {401_650, 300_600}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: -220, TyTo: 880, LoFrom: 0, LoTo: 1_005.5, Accuracy: -1.5},
+				{TyFrom: 880, TyTo: 1_760, LoFrom: 1_005.5, LoTo: 1_650.5, Accuracy: 12,
+					Model: ModelSpline, SlopeFrom: 0.8, SlopeTo: 0.7, Grade: GradeC},
+			},
+		},
+		"XYZ": {TyFrom: 0, TyTo: 100, Metric: true, Geometry: orb.LineString{{0, 0}, {91.44, 0}},
+			CalibrationSegments: []CalibrationSegment{{TyFrom: 0, TyTo: 100, LoFrom: 0, LoTo: 91.44, Method: MethodProportional}}},
+		"NOG": {TyFrom: 0, TyTo: 10},
+	}
+	for elr, e := range elrs {
+		e.Measures = cumulativeLengths(e.Geometry)
+		elrs[elr] = e
+	}
+	source := cacheSource{DataVersion: "6.8.1", DbSize: 1_234, DbModTime: 5_678}
+
+	var buf bytes.Buffer
+	if err := writeBinaryCache(&buf, elrs, source); err != nil {
+		t.Fatal(err)
+	}
+
+	gotSource, gotELRs, err := readBinaryCache(alignedCopy(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotSource != source {
+		t.Errorf("expected source %v, but got %v", source, gotSource)
+	}
+
+	if !reflect.DeepEqual(gotELRs, elrs) {
+		t.Errorf("expected ELRs %v, but got %v", elrs, gotELRs)
+	}
+
+	// Appending to a geometry read in place must not overwrite the following ELR geometry.
+	extended := append(gotELRs["ABC1"].Geometry, orb.Point{-1, -1})
+	if len(extended) != 4 || gotELRs["NOG"].Geometry != nil || gotELRs["XYZ"].Geometry[0] != (orb.Point{0, 0}) {
+		t.Errorf("append to geometry read in place corrupted adjacent data")
+	}
+}
+
+func TestBinaryCacheInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeBinaryCache(&buf, map[string]ELR{"ABC": {
+		Geometry:            orb.LineString{{0, 0}, {1, 1}},
+		CalibrationSegments: []CalibrationSegment{{TyFrom: 0, TyTo: 1, LoTo: 1.414}},
+	}}, cacheSource{}); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+	}{
+		{"empty", func(b []byte) []byte { return nil }},
+		{"truncated header", func(b []byte) []byte { return b[:binaryHeaderSize-1] }},
+		{"bad magic", func(b []byte) []byte { b[0] = 'X'; return b }},
+		{"bad format version", func(b []byte) []byte { b[8] = 99; return b }},
+		{"truncated body", func(b []byte) []byte { return b[:len(b)-8] }},
+		{"checksum mismatch in header", func(b []byte) []byte { b[32] ^= 0xff; return b }},
+		{"checksum mismatch in index", func(b []byte) []byte { b[binaryHeaderSize+8] ^= 0xff; return b }},
+		{"checksum mismatch in calibration", func(b []byte) []byte { b[len(b)-binaryCalibSize] ^= 0xff; return b }},
+	}
+
+	for _, test := range tests {
+		data := test.mutate(append([]byte(nil), valid...))
+		if len(data) > 0 {
+			data = alignedCopy(data)
+		}
+		if _, _, err := readBinaryCache(data); err == nil {
+			t.Errorf("%s: expected error, but got none", test.name)
+		}
+	}
+
+	if err := writeBinaryCache(&bytes.Buffer{}, map[string]ELR{"TOOLONGELR": {}}, cacheSource{}); err == nil {
+		t.Errorf("expected error writing overlong ELR code")
+	}
+}
+
+func TestBinaryCacheGeocoder(t *testing.T) {
+	dir := t.TempDir()
+	cfg := GeocoderConfig{
+		ProductionDbFn: filepath.Join(dir, "production.db"),
+		CacheFn:        filepath.Join(dir, "cache.bin"),
+		CacheFormat:    CacheBinary,
+	}
+
+	writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.0", 1_000)
+	built, err := NewGeocoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := NewGeocoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapped.unmap == nil {
+		t.Errorf("expected cache to be mapped")
+	}
+
+	if !reflect.DeepEqual(mapped.ELRs, built.ELRs) {
+		t.Errorf("expected mapped ELRs %v, but got %v", built.ELRs, mapped.ELRs)
+	}
+
+	pt, err := mapped.Point("ABC", 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Point != (orb.Point{500, 0}) {
+		t.Errorf("expected point (500, 0), but got %v", pt.Point)
+	}
+
+	// The spatial index, built while mapped, must not be read after the mapping is released.
+	if nearest, err := mapped.NearestELRs(orb.Point{500, 10}, 1); err != nil || len(nearest) != 1 || nearest[0].ELR != "ABC" {
+		t.Errorf("expected ABC nearest, but got %v (%v)", nearest, err)
+	}
+
+	found, err := mapped.Find("ABC", 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mapped.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Geometry returned by Find is copied out of the mapping, so remains valid after Close.
+	if len(found.Geometry) != 2 || found.Geometry[1] != (orb.Point{1_000, 0}) || found.Measures[1] != 1_000 {
+		t.Errorf("expected geometry copied from the mapping, but got %v %v", found.Geometry, found.Measures)
+	}
+
+	if err := mapped.Close(); err != nil {
+		t.Errorf("expected repeated Close to succeed, but got %v", err)
+	}
+
+	if _, err := mapped.NearestELRs(orb.Point{500, 10}, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from NearestELRs after Close, but got %v", err)
+	}
+	if _, err := mapped.Locate(orb.Point{500, 10}, 100); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Locate after Close, but got %v", err)
+	}
+
+	// Corrupt cache is reported when the production database is unavailable.
+	if err := os.Remove(cfg.ProductionDbFn); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.CacheFn, []byte("not a binary cache"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGeocoder(cfg); !errors.Is(err, ErrCacheCorrupt) {
+		t.Errorf("expected ErrCacheCorrupt, but got %v", err)
+	}
+}
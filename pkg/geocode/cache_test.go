@@ -0,0 +1,205 @@
+package geocode
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkb"
+)
+
+// writeTestProductionDb creates a minimal production database with a single ELR at the given data version.
+func writeTestProductionDb(t *testing.T, fn, version string, tyTo int) {
+	t.Helper()
+
+	os.Remove(fn)
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	geometry, err := wkb.Marshal(orb.LineString{{0, 0}, {float64(tyTo), 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{"CREATE TABLE elr (elr TEXT, total_yards_from INT, total_yards_to INT, shape_length_m REAL, l_system TEXT, geometry BLOB)", nil},
+		{"CREATE TABLE calibration (elr TEXT, total_yards_from INT, total_yards_to INT, linear_offset_from_m REAL, linear_offset_to_m REAL, accuracy INT, method TEXT, model TEXT, slope_from REAL, slope_to REAL, grade TEXT)", nil},
+		{"CREATE TABLE version (property TEXT NOT NULL, value TEXT NOT NULL, PRIMARY KEY(property))", nil},
+		{"INSERT INTO elr VALUES ('ABC', 0, ?, ?, 'M', ?)", []any{tyTo, tyTo, geometry}},
+		{"INSERT INTO calibration VALUES ('ABC', 0, ?, 0, ?, 0, 'calibrated', 'linear', 0.9144, 0.9144, 'A')", []any{tyTo, tyTo}},
+		{"INSERT INTO version VALUES ('version', ?)", []any{version}},
+	}
+
+	for _, s := range statements {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCacheRebuild(t *testing.T) {
+	dir := t.TempDir()
+	cfg := GeocoderConfig{ProductionDbFn: filepath.Join(dir, "production.db"), CacheFn: filepath.Join(dir, "cache.gob")}
+
+	writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.0", 1_000)
+	gc, err := NewGeocoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.ELRs["ABC"].TyTo != 1_000 {
+		t.Errorf("expected TyTo 1000 from database, but got %d", gc.ELRs["ABC"].TyTo)
+	}
+
+	header, _, err := readCache(cfg.CacheFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Source.DataVersion != "1.0.0" {
+		t.Errorf("expected cache data version 1.0.0, but got %q", header.Source.DataVersion)
+	}
+
+	// Cache is readable by other users of the production database.
+	if info, err := os.Stat(cfg.CacheFn); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("expected cache file mode 0644, but got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// Rebuilt production database invalidates the cache.
+	writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.1", 2_000)
+	if gc, err = NewGeocoder(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if gc.ELRs["ABC"].TyTo != 2_000 {
+		t.Errorf("expected stale cache to be rebuilt with TyTo 2000, but got %d", gc.ELRs["ABC"].TyTo)
+	}
+
+	// Cache is used as is when the production database is unavailable.
+	if err := os.Remove(cfg.ProductionDbFn); err != nil {
+		t.Fatal(err)
+	}
+	if gc, err = NewGeocoder(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if gc.ELRs["ABC"].TyTo != 2_000 {
+		t.Errorf("expected cached TyTo 2000, but got %d", gc.ELRs["ABC"].TyTo)
+	}
+
+	// No temporary files remain from the atomic writes.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the cache file to remain, but got %d entries", len(entries))
+	}
+}
+
+func TestCacheChecksum(t *testing.T) {
+	dir := t.TempDir()
+	cfg := GeocoderConfig{ProductionDbFn: filepath.Join(dir, "production.db"), CacheFn: filepath.Join(dir, "cache.gob")}
+
+	writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.0", 1_000)
+	if _, err := NewGeocoder(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte at the end of the payload.
+	data, err := os.ReadFile(cfg.CacheFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(cfg.CacheFn, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := readCache(cfg.CacheFn); !errors.Is(err, ErrCacheCorrupt) {
+		t.Errorf("expected ErrCacheCorrupt for tampered cache, but got %v", err)
+	}
+
+	// Corrupt cache is rebuilt from the production database.
+	if _, err := NewGeocoder(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readCache(cfg.CacheFn); err != nil {
+		t.Errorf("expected rebuilt cache to be valid, but got %v", err)
+	}
+}
+
+func TestCacheBuildLocate(t *testing.T) {
+	for _, format := range []CacheFormat{CacheGob, CacheBinary} {
+		dir := t.TempDir()
+		cfg := GeocoderConfig{
+			ProductionDbFn: filepath.Join(dir, "production.db"),
+			CacheFn:        filepath.Join(dir, "cache"),
+			CacheFormat:    format,
+		}
+
+		// Fresh, then stale and corrupt caches are (re)built, leaving the Geocoder open for spatial queries.
+		for i, version := range []string{"1.0.0", "1.0.1", "corrupt"} {
+			if version == "corrupt" {
+				if err := os.WriteFile(cfg.CacheFn, []byte("not a cache"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				writeTestProductionDb(t, cfg.ProductionDbFn, version, 1_000)
+			}
+
+			gc, err := NewGeocoder(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			locations, err := gc.Locate(orb.Point{500, 10}, 100)
+			if err != nil || len(locations) != 1 || locations[0].ELR != "ABC" {
+				t.Errorf("format %d, build %d: expected ABC located, but got %v (%v)", format, i, locations, err)
+			}
+			gc.Close()
+		}
+	}
+}
+
+func TestOpenCacheStale(t *testing.T) {
+	for _, format := range []CacheFormat{CacheGob, CacheBinary} {
+		dir := t.TempDir()
+		cfg := GeocoderConfig{
+			ProductionDbFn: filepath.Join(dir, "production.db"),
+			CacheFn:        filepath.Join(dir, "cache"),
+			CacheFormat:    format,
+		}
+
+		writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.0", 1_000)
+		if _, err := NewGeocoder(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		// Corrupt the payload, leaving the header intact.
+		data, err := os.ReadFile(cfg.CacheFn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 0xff
+		if err := os.WriteFile(cfg.CacheFn, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		// A stale cache is identified from its header alone, without decoding the payload.
+		gc := &Geocoder{config: cfg}
+		source, err := gc.openCache(func(cacheSource) bool { return false })
+		if err != nil || source.DataVersion != "1.0.0" || gc.ELRs != nil {
+			t.Errorf("format %d: expected stale source 1.0.0 without ELRs, but got %+v, %d ELRs (%v)", format, source, len(gc.ELRs), err)
+		}
+
+		if _, err := gc.openCache(func(cacheSource) bool { return true }); !errors.Is(err, ErrCacheCorrupt) {
+			t.Errorf("format %d: expected ErrCacheCorrupt decoding fresh cache, but got %v", format, err)
+		}
+	}
+}
@@ -2,35 +2,237 @@
 
 package geocode
 
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CalibrationMethod represents how the linear calibration of a segment was derived.
+type CalibrationMethod uint8
+
+const (
+	MethodMilepost     CalibrationMethod = iota // Calibrated against mileposts.
+	MethodProportional                          // Uncalibrated, proportional from the reported extent against the measured length.
+)
+
+// String returns the description of the calibration method, as stored in the calibration databases.
+func (m CalibrationMethod) String() string {
+	switch m {
+	case MethodMilepost:
+		return "calibrated"
+	case MethodProportional:
+		return "uncalibrated proportional"
+	default:
+		return fmt.Sprintf("CalibrationMethod(%d)", m)
+	}
+}
+
+// ParseCalibrationMethod returns the calibration method from its description.
+func ParseCalibrationMethod(s string) (CalibrationMethod, error) {
+	for _, m := range []CalibrationMethod{MethodMilepost, MethodProportional} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognised calibration method: %q", s)
+}
+
+// CalibrationModel represents the model interpolating linear offset against mileage within calibration segments.
+type CalibrationModel uint8
+
+const (
+	ModelLinear CalibrationModel = iota // Piecewise-linear between calibration points.
+	ModelSpline                         // Monotone cubic (Fritsch-Carlson) through calibration points.
+	ModelRobust                         // Robust least-squares line fitted to calibration points, tolerating bad points.
+)
+
+// String returns the name of the calibration model, as stored in the calibration databases.
+func (m CalibrationModel) String() string {
+	switch m {
+	case ModelLinear:
+		return "linear"
+	case ModelSpline:
+		return "spline"
+	case ModelRobust:
+		return "robust"
+	default:
+		return fmt.Sprintf("CalibrationModel(%d)", m)
+	}
+}
+
+// ParseCalibrationModel returns the calibration model from its name.
+func ParseCalibrationModel(s string) (CalibrationModel, error) {
+	for _, m := range []CalibrationModel{ModelLinear, ModelSpline, ModelRobust} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognised calibration model: %q", s)
+}
+
+// CalibrationGrade represents the quality of calibration, from A (best) to E (worst).
+type CalibrationGrade uint8
+
+const (
+	GradeNone CalibrationGrade = iota // Not graded.
+	GradeA                            // Closely calibrated against well-placed, frequent mileposts.
+	GradeB
+	GradeC
+	GradeD
+	GradeE // Poorly calibrated, or not calibrated against any milepost.
+)
+
+// String returns the letter of the calibration grade, as stored in the calibration databases, empty if not graded.
+func (g CalibrationGrade) String() string {
+	switch {
+	case g == GradeNone:
+		return ""
+	case g <= GradeE:
+		return string(rune('A' + g - GradeA))
+	default:
+		return fmt.Sprintf("CalibrationGrade(%d)", g)
+	}
+}
+
+// ParseCalibrationGrade returns the calibration grade from its letter, not graded if empty.
+func ParseCalibrationGrade(s string) (CalibrationGrade, error) {
+	for g := GradeNone; g <= GradeE; g++ {
+		if s == g.String() {
+			return g, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognised calibration grade: %q", s)
+}
+
 // CalibrationPoint represents linear calibration values at a railway point.
 type CalibrationPoint struct {
-	Ty           int     // Total yards.
-	LoMetres     float64 // Linear offset (metres).
-	LoNormalised float64 // Linear offset (normalised 0 -> 1).
+	Ty             int     // Total yards.
+	LoMetres       float64 // Linear offset (metres), measured on the National Grid for positioning.
+	LoNormalised   float64 // Linear offset (normalised 0 -> 1).
+	LoGroundMetres float64 // Linear offset (metres), measured on the ground for accuracy, correcting for grid scale factor.
+	Source         string  // Provenance of the calibration point.
+	Slope          float64 // Rate of change of linear offset with mileage (metres per yard), as fitted by the model.
+	Distance       float64 // Distance (metres) of the surveyed position from the centre-line, zero for the ELR extent.
 }
 
 // CalibrationSegment represents linear calibration values between two railway points.
 type CalibrationSegment struct {
-	TyFrom   int     // Low mileage end of calibration segment (as total yards).
-	TyTo     int     // High mileage end of calibration segment (as total yards).
-	LoFrom   float64 // Linear offset (metres) at low mileage end.
-	LoTo     float64 // Linear offset (metres) at high mileage end.
-	Accuracy float64 // Accuracy of calibration segment, comparing reported versus measured length (metres).
+	TyFrom    int               // Low mileage end of calibration segment (as total yards).
+	TyTo      int               // High mileage end of calibration segment (as total yards).
+	LoFrom    float64           // Linear offset (metres) at low mileage end.
+	LoTo      float64           // Linear offset (metres) at high mileage end.
+	Accuracy  float64           // Accuracy of calibration segment, comparing reported versus measured length (metres).
+	Method    CalibrationMethod // Method by which the calibration segment was derived.
+	Model     CalibrationModel  // Model interpolating linear offset against mileage within the segment.
+	SlopeFrom float64           // Rate of change of linear offset with mileage (metres per yard) at low mileage end.
+	SlopeTo   float64           // Rate of change of linear offset with mileage (metres per yard) at high mileage end.
+	Grade     CalibrationGrade  // Quality of the calibration segment.
 }
 
 // CalibrationSegmentNormalised represents linear calibration values (including normalised values) between two railway points.
 type CalibrationSegmentNormalised struct {
-	TyFrom           int     // Low mileage end of calibration segment (as total yards).
-	TyTo             int     // High mileage end of calibration segment (as total yards).
-	LoMetresFrom     float64 // Linear offset (metres) at low mileage end.
-	LoMetresTo       float64 // Linear offset (metres) at high mileage end.
-	LoNormalisedFrom float64 // Linear offset (normalised 0 -> 1) at low mileage end.
-	LoNormalisedTo   float64 // Linear offset (normalised 0 -> 1) at high mileage end.
-	Accuracy         float64 // Accuracy (metres).
-	QmNormalised     float64 // "Normalised" quarter mile length (relative to 440 yards).
+	TyFrom           int               // Low mileage end of calibration segment (as total yards).
+	TyTo             int               // High mileage end of calibration segment (as total yards).
+	LoMetresFrom     float64           // Linear offset (metres) at low mileage end.
+	LoMetresTo       float64           // Linear offset (metres) at high mileage end.
+	LoNormalisedFrom float64           // Linear offset (normalised 0 -> 1) at low mileage end.
+	LoNormalisedTo   float64           // Linear offset (normalised 0 -> 1) at high mileage end.
+	Accuracy         float64           // Accuracy (metres).
+	QmNormalised     float64           // "Normalised" quarter mile length (relative to 440 yards).
+	SourceFrom       string            // Provenance of the calibration point at low mileage end.
+	SourceTo         string            // Provenance of the calibration point at high mileage end.
+	Method           CalibrationMethod // Method by which the calibration segment was derived.
+	Model            CalibrationModel  // Model interpolating linear offset against mileage within the segment.
+	SlopeFrom        float64           // Rate of change of linear offset with mileage (metres per yard) at low mileage end.
+	SlopeTo          float64           // Rate of change of linear offset with mileage (metres per yard) at high mileage end.
+	Grade            CalibrationGrade  // Quality of the calibration segment.
 }
 
-// interpolateSegment returns the linear interpolated offset value within a given linear calibration segment.
+// interpolateSegment returns the interpolated offset value within a given calibration segment, according to its model.
+// Spline segments are extrapolated linearly along the end slopes, rather than along the cubic.
 func interpolateSegment(tyTarget int, c CalibrationSegment) float64 {
-	return c.LoFrom + (float64(tyTarget)-float64(c.TyFrom))/(float64(c.TyTo)-float64(c.TyFrom))*(c.LoTo-c.LoFrom)
+	if c.Model != ModelSpline || c.TyTo == c.TyFrom {
+		return c.LoFrom + (float64(tyTarget)-float64(c.TyFrom))/(float64(c.TyTo)-float64(c.TyFrom))*(c.LoTo-c.LoFrom)
+	}
+
+	h := float64(c.TyTo - c.TyFrom)
+	x := float64(tyTarget - c.TyFrom)
+	switch {
+	case x < 0:
+		return c.LoFrom + c.SlopeFrom*x
+	case x > h:
+		return c.LoTo + c.SlopeTo*(x-h)
+	}
+
+	// Cubic Hermite basis functions.
+	t := x / h
+	t2, t3 := t*t, t*t*t
+	return (2*t3-3*t2+1)*c.LoFrom + (t3-2*t2+t)*h*c.SlopeFrom + (-2*t3+3*t2)*c.LoTo + (t3-t2)*h*c.SlopeTo
+}
+
+// InterpolateCalibration returns the linear offset (metres) at the mileage (as total yards) from the calibration
+// segments of an ELR, as interpolated when geocoding. Mileages beyond the calibrated extent are extrapolated from the
+// respective end segment. Returns false if there are no calibration segments.
+func InterpolateCalibration(calibrationSegments []CalibrationSegment, ty int) (float64, bool) {
+	n := len(calibrationSegments)
+	if n == 0 {
+		return 0, false
+	}
+
+	i, ok := findCalibrationIndex(calibrationSegments, ty)
+	if !ok && ty > calibrationSegments[n-1].TyTo {
+		i = n - 1
+	}
+
+	return interpolateSegment(ty, calibrationSegments[i]), true
+}
+
+// findCalibrationSegmentByOffset returns the calibration segment containing the linear offset (metres).
+// Offsets before the first or beyond the last segment return the respective end segment, so that the
+// caller extrapolates from the nearest calibration.
+func findCalibrationSegmentByOffset(calibrationSegments []CalibrationSegment, lo float64) (CalibrationSegment, bool) {
+	if len(calibrationSegments) == 0 {
+		return CalibrationSegment{}, false
+	}
+
+	// Calibration slice is sorted by total yards from, and linear offsets increase with total yards.
+	i := sort.Search(len(calibrationSegments), func(i int) bool { return calibrationSegments[i].LoTo >= lo })
+	if i == len(calibrationSegments) {
+		i--
+	}
+
+	return calibrationSegments[i], true
+}
+
+// inverseInterpolateSegment returns the total yards for a linear offset (metres) within a given calibration segment, according to its model.
+func inverseInterpolateSegment(lo float64, c CalibrationSegment) int {
+	if c.LoTo == c.LoFrom {
+		// Degenerate segment (coincident mileposts), so no mileage can be resolved within it.
+		return c.TyFrom
+	}
+
+	if c.Model == ModelSpline && lo >= c.LoFrom && lo <= c.LoTo {
+		// Monotone within the segment, so search for the nearest mileage.
+		i := sort.Search(c.TyTo-c.TyFrom, func(i int) bool { return interpolateSegment(c.TyFrom+i, c) >= lo })
+		ty := c.TyFrom + i
+		if ty > c.TyFrom && lo-interpolateSegment(ty-1, c) < interpolateSegment(ty, c)-lo {
+			ty--
+		}
+		return ty
+	}
+
+	// Beyond a spline segment, so invert the linear extrapolation along the end slope.
+	switch {
+	case c.Model == ModelSpline && lo < c.LoFrom && c.SlopeFrom > 0:
+		return c.TyFrom + int(math.Round((lo-c.LoFrom)/c.SlopeFrom))
+	case c.Model == ModelSpline && lo > c.LoTo && c.SlopeTo > 0:
+		return c.TyTo + int(math.Round((lo-c.LoTo)/c.SlopeTo))
+	}
+
+	ty := float64(c.TyFrom) + (lo-c.LoFrom)/(c.LoTo-c.LoFrom)*(float64(c.TyTo)-float64(c.TyFrom))
+	return int(math.Round(ty))
 }
@@ -1,6 +1,7 @@
 package geocode
 
 import (
+	"math"
 	"testing"
 )
 
@@ -56,3 +57,199 @@ func TestInterpolateSegment(t *testing.T) {
 		}
 	}
 }
+
+func TestInverseInterpolateSegment(t *testing.T) {
+	cases := []struct {
+		lo          float64
+		calibration CalibrationSegment
+		expected    int
+	}{
+		{
+			lo: 914.4 / 4.0,
+			calibration: CalibrationSegment{
+				TyFrom: 500,
+				TyTo:   1_000,
+				LoFrom: 0,
+				LoTo:   914.4},
+			expected: 625},
+		{
+			lo: 0.5,
+			calibration: CalibrationSegment{
+				TyFrom: 10_000,
+				TyTo:   20_000,
+				LoFrom: 0,
+				LoTo:   1},
+			expected: 15_000},
+		{ // Degenerate segment.
+			lo: 50,
+			calibration: CalibrationSegment{
+				TyFrom: 100,
+				TyTo:   200,
+				LoFrom: 50,
+				LoTo:   50},
+			expected: 100},
+	}
+
+	for _, c := range cases {
+		ty := inverseInterpolateSegment(c.lo, c.calibration)
+		if ty != c.expected {
+			t.Log("error, should be:", c.expected, "but got:", ty)
+			t.Fail()
+		}
+	}
+}
+
+func TestFindCalibrationSegmentByOffset(t *testing.T) {
+	calibrationSegments := []CalibrationSegment{
+		{TyFrom: 0, TyTo: 440, LoFrom: 0, LoTo: 400},
+		{TyFrom: 440, TyTo: 880, LoFrom: 400, LoTo: 805},
+		{TyFrom: 880, TyTo: 1_000, LoFrom: 805, LoTo: 910},
+	}
+
+	cases := []struct {
+		lo           float64
+		expectedFrom int
+	}{
+		{lo: -10, expectedFrom: 0},
+		{lo: 0, expectedFrom: 0},
+		{lo: 400, expectedFrom: 0},
+		{lo: 401, expectedFrom: 440},
+		{lo: 900, expectedFrom: 880},
+		{lo: 2_000, expectedFrom: 880},
+	}
+
+	for _, c := range cases {
+		calib, ok := findCalibrationSegmentByOffset(calibrationSegments, c.lo)
+		if !ok || calib.TyFrom != c.expectedFrom {
+			t.Errorf("findCalibrationSegmentByOffset(%v) = %v, %v; want segment from %d", c.lo, calib, ok, c.expectedFrom)
+		}
+	}
+
+	if _, ok := findCalibrationSegmentByOffset(nil, 0); ok {
+		t.Error("expected no calibration segment for empty calibration")
+	}
+}
+
+func TestCalibrationMethod(t *testing.T) {
+	cases := []struct {
+		method   CalibrationMethod
+		expected string
+	}{
+		{MethodMilepost, "calibrated"},
+		{MethodProportional, "uncalibrated proportional"},
+	}
+
+	for _, c := range cases {
+		if got := c.method.String(); got != c.expected {
+			t.Errorf("Expected %q, but got %q", c.expected, got)
+		}
+
+		if got, err := ParseCalibrationMethod(c.expected); err != nil || got != c.method {
+			t.Errorf("Expected %v, but got %v, %v", c.method, got, err)
+		}
+	}
+
+	if _, err := ParseCalibrationMethod("guessed"); err == nil {
+		t.Error("Expected error for unrecognised calibration method")
+	}
+}
+
+func TestInterpolateSplineSegment(t *testing.T) {
+	// Linear offset rising slowly then quickly across the segment, with linear extrapolation beyond it.
+	c := CalibrationSegment{TyFrom: 1_000, TyTo: 2_000, LoFrom: 100, LoTo: 1_100, Model: ModelSpline, SlopeFrom: 0.5, SlopeTo: 1.5}
+
+	cases := []struct {
+		ty       int
+		expected float64
+	}{
+		{1_000, 100},
+		{2_000, 1_100},
+		{1_500, 100 + 500 + 0.125*1_000*(0.5-1.5)},
+		{900, 50},
+		{2_100, 1_250},
+	}
+
+	for _, tc := range cases {
+		if got := interpolateSegment(tc.ty, c); math.Abs(got-tc.expected) > 1e-9 {
+			t.Errorf("interpolateSegment(%d) = %v; want %v", tc.ty, got, tc.expected)
+		}
+
+		if got := inverseInterpolateSegment(tc.expected, c); got != tc.ty {
+			t.Errorf("inverseInterpolateSegment(%v) = %d; want %d", tc.expected, got, tc.ty)
+		}
+	}
+
+	previous := math.Inf(-1)
+	for ty := c.TyFrom; ty <= c.TyTo; ty++ {
+		lo := interpolateSegment(ty, c)
+		if lo < previous {
+			t.Fatalf("interpolateSegment not monotone at %d: %v < %v", ty, lo, previous)
+		}
+		previous = lo
+	}
+}
+
+func TestCalibrationModel(t *testing.T) {
+	for _, m := range []CalibrationModel{ModelLinear, ModelSpline, ModelRobust} {
+		if got, err := ParseCalibrationModel(m.String()); err != nil || got != m {
+			t.Errorf("Expected %v, but got %v, %v", m, got, err)
+		}
+	}
+
+	if _, err := ParseCalibrationModel("quadratic"); err == nil {
+		t.Error("Expected error for unrecognised calibration model")
+	}
+}
+
+func TestInterpolateCalibration(t *testing.T) {
+	calibrationSegments := []CalibrationSegment{
+		{TyFrom: 0, TyTo: 440, LoFrom: 0, LoTo: 400},
+		{TyFrom: 440, TyTo: 880, LoFrom: 400, LoTo: 840},
+	}
+
+	cases := []struct {
+		ty       int
+		expected float64
+	}{
+		{-220, -200},
+		{220, 200},
+		{660, 620},
+		{1_100, 1_060},
+	}
+
+	for _, c := range cases {
+		if lo, ok := InterpolateCalibration(calibrationSegments, c.ty); !ok || math.Abs(lo-c.expected) > 1e-9 {
+			t.Errorf("InterpolateCalibration(%d) = %v, %v; want %v", c.ty, lo, ok, c.expected)
+		}
+	}
+
+	if _, ok := InterpolateCalibration(nil, 0); ok {
+		t.Error("Expected no linear offset for empty calibration")
+	}
+}
+
+func TestCalibrationGrade(t *testing.T) {
+	cases := []struct {
+		grade    CalibrationGrade
+		expected string
+	}{
+		{GradeNone, ""},
+		{GradeA, "A"},
+		{GradeC, "C"},
+		{GradeE, "E"},
+	}
+
+	for _, c := range cases {
+		if got := c.grade.String(); got != c.expected {
+			t.Errorf("Expected %q, but got %q", c.expected, got)
+		}
+
+		if got, err := ParseCalibrationGrade(c.expected); err != nil || got != c.grade {
+			t.Errorf("Expected %v, but got %v, %v", c.grade, got, err)
+		}
+	}
+
+	if _, err := ParseCalibrationGrade("F"); err == nil {
+		t.Error("Expected error for unrecognised calibration grade")
+	}
+}
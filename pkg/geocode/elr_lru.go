@@ -0,0 +1,73 @@
+// Least recently used cache of ELRs, bounding the ELRs resident in memory when lazy loading.
+
+package geocode
+
+import (
+	"container/list"
+	"sync"
+)
+
+// elrLRU represents a fixed capacity cache of ELRs, evicting the least recently used; safe for concurrent use.
+type elrLRU struct {
+	mu       sync.Mutex               // Guards order and entries.
+	capacity int                      // Maximum number of resident ELRs.
+	order    *list.List               // Resident ELRs, most recently used first.
+	entries  map[string]*list.Element // Resident ELRs by code.
+}
+
+// elrLRUEntry represents a resident ELR.
+type elrLRUEntry struct {
+	code string // ELR code.
+	elr  ELR    // ELR properties, geometry and calibration.
+}
+
+// newELRLRU returns an empty cache with the given capacity (minimum one).
+func newELRLRU(capacity int) *elrLRU {
+	return &elrLRU{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		entries:  make(map[string]*list.Element, capacity),
+	}
+}
+
+// get returns the resident ELR, marking it as most recently used.
+func (c *elrLRU) get(code string) (ELR, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[code]
+	if !ok {
+		return ELR{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*elrLRUEntry).elr, true
+}
+
+// add makes the ELR resident as most recently used, evicting the least recently used ELR if at capacity.
+func (c *elrLRU) add(code string, elr ELR) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[code]; ok {
+		element.Value.(*elrLRUEntry).elr = elr
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*elrLRUEntry).code)
+	}
+
+	c.entries[code] = c.order.PushFront(&elrLRUEntry{code: code, elr: elr})
+}
+
+// len returns the number of resident ELRs.
+func (c *elrLRU) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,39 @@
+package geocode
+
+import "testing"
+
+func TestELRLRU(t *testing.T) {
+	c := newELRLRU(2)
+
+	c.add("AAA", ELR{TyTo: 1})
+	c.add("BBB", ELR{TyTo: 2})
+
+	// Using AAA leaves BBB as least recently used, so it is evicted by CCC.
+	if _, ok := c.get("AAA"); !ok {
+		t.Errorf("expected AAA to be resident")
+	}
+	c.add("CCC", ELR{TyTo: 3})
+
+	tests := []struct {
+		elr      string
+		resident bool
+		tyTo     int
+	}{
+		{"AAA", true, 1},
+		{"BBB", false, 0},
+		{"CCC", true, 3},
+	}
+
+	for _, test := range tests {
+		e, ok := c.get(test.elr)
+		if ok != test.resident || e.TyTo != test.tyTo {
+			t.Errorf("%s: expected resident %t with TyTo %d, but got %t with %d", test.elr, test.resident, test.tyTo, ok, e.TyTo)
+		}
+	}
+
+	// Re-adding a resident ELR replaces it without eviction.
+	c.add("CCC", ELR{TyTo: 30})
+	if e, _ := c.get("CCC"); e.TyTo != 30 || c.len() != 2 {
+		t.Errorf("expected CCC replaced with 2 resident, but got TyTo %d with %d resident", e.TyTo, c.len())
+	}
+}
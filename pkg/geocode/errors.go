@@ -0,0 +1,28 @@
+// Errors returned by the geocoding functions, for use with errors.Is and errors.As.
+
+package geocode
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownELR   = errors.New("unknown ELR")                   // ELR is not present in the production data.
+	ErrCacheCorrupt = errors.New("geocoder cache corrupt")        // Serialised cache could not be read.
+	ErrProjection   = errors.New("co-ordinate projection failed") // PROJ transformation could not be created or applied.
+	ErrClosed       = errors.New("geocoder closed")               // Geocoder has been closed.
+)
+
+// ErrMileageOutOfRange represents a mileage outside of the calibrated extent of an ELR.
+type ErrMileageOutOfRange struct {
+	ELR string // ELR code.
+	Ty  int    // Requested mileage (as total yards).
+	Min int    // Low mileage end of calibration (as total yards).
+	Max int    // High mileage end of calibration (as total yards).
+}
+
+// Error returns the description of the out of range mileage.
+func (e ErrMileageOutOfRange) Error() string {
+	return fmt.Sprintf("no calibration found for ELR %s at total yards %d (calibrated from %d to %d)", e.ELR, e.Ty, e.Min, e.Max)
+}
@@ -4,11 +4,15 @@ package geocode
 
 import (
 	"database/sql"
-	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
-	"os"
+	"math"
 	"sort"
+	"sync"
+	"sync/atomic"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/paulmach/orb"
@@ -23,15 +27,39 @@ const (
 
 // RailwayPoint represents a geographic position and associated linear accuracy.
 type RailwayPoint struct {
-	Point    orb.Point // Easting / Northing to EPSG:27700 (metres).
-	Accuracy float64   // Calibrated linear accuracy along railway (metres).
+	Point      orb.Point         // Easting / Northing to EPSG:27700 (metres).
+	Accuracy   float64           // Calibrated linear accuracy along railway (metres).
+	Adjustment Adjustment        // Adjustment made for a mileage beyond the calibrated extent.
+	Overshoot  float64           // Distance the mileage lies beyond the calibrated extent (metres), zero if within.
+	Method     CalibrationMethod // Method by which the calibration at the mileage was derived.
+	Grade      CalibrationGrade  // Quality of the calibration at the mileage.
+}
+
+// SubstringResult represents a portion of an ELR centre-line, with the calibration detail of the mileage range.
+type SubstringResult struct {
+	Geometry       orb.LineString       // Easting / Northing linestring to EPSG:27700 (metres).
+	AccuracyFrom   float64              // Calibrated linear accuracy at the start mileage (metres).
+	AccuracyTo     float64              // Calibrated linear accuracy at the end mileage (metres).
+	WorstAccuracy  float64              // Accuracy of greatest magnitude of all calibration segments spanned (metres).
+	MeasuredLength float64              // Geographic length of the geometry (metres).
+	ReportedLength float64              // Length between the start and end mileages (metres).
+	Segments       []CalibrationSegment // Calibration segments spanned, in order of increasing mileage.
+	AdjustmentFrom Adjustment           // Adjustment made for a start mileage beyond the calibrated extent.
+	AdjustmentTo   Adjustment           // Adjustment made for an end mileage beyond the calibrated extent.
+	OvershootFrom  float64              // Distance the start mileage lies beyond the calibrated extent (metres).
+	OvershootTo    float64              // Distance the end mileage lies beyond the calibrated extent (metres).
 }
 
 // GeocoderConfig represents the production database and cache filenames.
 type GeocoderConfig struct {
-	ProductionDbFn string // Filename of the production database containing ELR and calibration.
-	CacheFn        string // Filename of the serialised cache of ELR and calibration.
-	VerboseOutput  bool   // Show logging output in event of no calibration segment being found.
+	ProductionDbFn  string      // Filename of the production database containing ELR and calibration.
+	CacheFn         string      // Filename of the serialised cache of ELR and calibration.
+	VerboseOutput   bool        // Show logging output in event of no calibration segment being found.
+	Lookup          LookupMode  // Treatment of mileages beyond the calibrated extent of an ELR (default strict).
+	Workers         int         // Number of concurrent workers for batch geocoding (default number of CPUs).
+	CacheFormat     CacheFormat // On-disk layout of the serialised cache (default gob).
+	LazyLoad        bool        // Load each ELR from the production database on first use, rather than all from the cache.
+	MaxResidentELRs int         // Maximum ELRs with geometry and calibration held in memory when lazy loading (default 100).
 }
 
 // ELR represents a single ELR with its associated linear calibration segments.
@@ -41,21 +69,21 @@ type ELR struct {
 	ShapeLen            float64              // Geometry linestring length (metres).
 	Metric              bool                 // Linear referencing reporting unit system.
 	Geometry            orb.LineString       // Geometry of the centre-line 2D linestring.
+	Measures            []float64            // Cumulative distance (metres) along the geometry at each vertex.
 	CalibrationSegments []CalibrationSegment // Calibration segments.
 }
 
 // Geocoder represents the primary interface offering railway mileage geocoding.
 type Geocoder struct {
-	ELRs    map[string]ELR  // ELRs with reported extents, geometry, and calibration.
-	Metrics map[string]bool // Metric ELRs (reported extents in kilometres).
-	config  GeocoderConfig  // Configuration settings.
-}
-
-// check aborts if an error is passed in.
-func Check(err error) {
-	if err != nil {
-		log.Fatal(err)
-	}
+	ELRs      map[string]ELR               // ELRs with reported extents, geometry (valid until Close if memory-mapped), and calibration.
+	Metrics   map[string]bool              // Metric ELRs (reported extents in kilometres).
+	config    GeocoderConfig               // Configuration settings.
+	index     atomic.Pointer[spatialIndex] // Spatial index of ELR centre-lines, nil until built or once closed.
+	indexOnce sync.Once                    // Guards building of the spatial index.
+	indexErr  error                        // Error building the spatial index, if any.
+	unmap     func() error                 // Releases the memory-mapped cache, if any.
+	lazy      *lazyLoader                  // Production database and resident ELRs, if lazy loading.
+	closed    atomic.Bool                  // Geocoder has been closed.
 }
 
 // NewGeocoder is a constructor function to return a Geocoder.
@@ -63,15 +91,125 @@ func NewGeocoder(cfg GeocoderConfig) (*Geocoder, error) {
 	gc := &Geocoder{}
 	gc.config = cfg
 
-	err := gc.loadELRs()
+	var err error
+	if cfg.LazyLoad {
+		err = gc.openLazy()
+	} else {
+		err = gc.loadELRs()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load ELRs and calibration: %w", err)
 	}
 
+	// Spatial index is built on first use, so start-up isn't delayed for mileage geocoding alone.
+	gc.Metrics = gc.MetricELRs()
+	return gc, nil
+}
+
+// NewGeocoderFromReader returns a Geocoder with ELRs and calibration read from a serialised cache, in the
+// configured cache format. The cache is not checked against the production database, and filenames are ignored.
+func NewGeocoderFromReader(r io.Reader, cfg GeocoderConfig) (*Geocoder, error) {
+	gc := &Geocoder{config: cfg}
+
+	if cfg.CacheFormat == CacheBinary {
+		data, err := readAligned(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache: %w", err)
+		}
+		if _, gc.ELRs, err = readBinaryCache(data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCacheCorrupt, err)
+		}
+	} else {
+		_, payload, err := readCacheFrom(r, "reader")
+		if err != nil {
+			return nil, err
+		}
+		if err := gc.decodeCache(payload, "reader"); err != nil {
+			return nil, err
+		}
+	}
+
+	gc.Metrics = gc.MetricELRs()
+	return gc, nil
+}
+
+// NewGeocoderFromFS returns a Geocoder with ELRs and calibration read from the serialised cache file within
+// the file system (such as an embed.FS), in the configured cache format.
+func NewGeocoderFromFS(fsys fs.FS, name string, cfg GeocoderConfig) (*Geocoder, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer file.Close()
+
+	return NewGeocoderFromReader(file, cfg)
+}
+
+// NewGeocoderFromDB returns a Geocoder with ELRs and calibration read from an open production database, either
+// up front or on first use if lazy loading. The database remains owned by the caller, and must remain open while
+// a lazy loading Geocoder is in use.
+func NewGeocoderFromDB(db *sql.DB, cfg GeocoderConfig) (*Geocoder, error) {
+	gc := &Geocoder{config: cfg}
+
+	var err error
+	if cfg.LazyLoad {
+		err = gc.openLazyDB(db, false)
+	} else {
+		err = gc.importELRs(db)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ELRs and calibration: %w", err)
+	}
+
+	gc.Metrics = gc.MetricELRs()
+	return gc, nil
+}
+
+// NewGeocoderFromELRs returns a Geocoder over the given ELRs, such as hand-built fixtures for testing.
+// Calibration segments of each ELR must be in order of increasing mileage.
+func NewGeocoderFromELRs(elrs map[string]ELR, cfg GeocoderConfig) (*Geocoder, error) {
+	for elr, e := range elrs {
+		for i, c := range e.CalibrationSegments {
+			if c.TyFrom > c.TyTo || (i > 0 && c.TyFrom < e.CalibrationSegments[i-1].TyTo) {
+				return nil, fmt.Errorf("calibration segments of ELR %s out of order at index %d", elr, i)
+			}
+		}
+	}
+
+	gc := &Geocoder{ELRs: make(map[string]ELR, len(elrs)), config: cfg}
+	for elr, e := range elrs {
+		if len(e.Measures) != len(e.Geometry) {
+			e.Measures = cumulativeLengths(e.Geometry)
+		}
+		gc.ELRs[elr] = e
+	}
+
 	gc.Metrics = gc.MetricELRs()
 	return gc, nil
 }
 
+// Close releases the memory-mapped cache or production database, if any, and the spatial index, returning the
+// errors of each. Calls made after closing return ErrClosed, but Close must not be called while other calls are
+// in progress. Geometry read directly from the ELRs of a memory-mapped cache is invalid after closing, whereas
+// that returned by Find is copied.
+func (gc *Geocoder) Close() error {
+	gc.closed.Store(true)
+	gc.index.Store(nil)
+
+	var errs []error
+	if gc.unmap != nil {
+		errs = append(errs, gc.unmap())
+		gc.unmap, gc.ELRs = nil, nil
+	}
+
+	if gc.lazy != nil {
+		errs = append(errs, gc.lazy.close())
+		gc.lazy, gc.ELRs = nil, nil
+	}
+
+	return errors.Join(errs...)
+}
+
 // MetricELRs returns a map of ELRs that are reported in kilometres.
 func (gc *Geocoder) MetricELRs() map[string]bool {
 	metrics := make(map[string]bool)
@@ -103,7 +241,7 @@ func (gc *Geocoder) AllELRs() []string {
 // Point returns the point for a given distance (as total yards) on the ELR linestring,
 // with linear offset accuracy reported by referring to the milepost calibration points.
 func (gc *Geocoder) Point(elr string, ty int) (RailwayPoint, error) {
-	elrSegment, err := gc.Find(elr, ty)
+	m, err := gc.match(elr, ty)
 	if err != nil {
 		if gc.config.VerboseOutput {
 			log.Printf(calibrationNotFound, elr, ty)
@@ -111,139 +249,157 @@ func (gc *Geocoder) Point(elr string, ty int) (RailwayPoint, error) {
 		return RailwayPoint{}, err
 	}
 
-	distance := interpolateSegment(ty, elrSegment.CalibrationSegments[0])
-	return RailwayPoint{
-			Point:    pointAtDistanceAlongLine(distance, elrSegment.Geometry),
-			Accuracy: elrSegment.CalibrationSegments[0].Accuracy},
-		nil
+	return m.railwayPoint(), nil
 }
 
 // Substring returns a portion of the ELR linestring based on the start and end distances (as total yards),
-// interpolating linearly as necessary between linestring points.
-func (gc *Geocoder) Substring(elr string, tyFrom, tyTo int) (orb.LineString, error) {
-	// NOTE: Linear accuracy for either end of the substring is not currently returned.
-	elrSegmentFrom, err := gc.Find(elr, tyFrom)
+// interpolating linearly as necessary between linestring points, with the linear accuracy at either end
+// and of the calibration segments spanned.
+func (gc *Geocoder) Substring(elr string, tyFrom, tyTo int) (SubstringResult, error) {
+	mFrom, err := gc.match(elr, tyFrom)
 	if err != nil {
 		if gc.config.VerboseOutput {
 			log.Printf(calibrationNotFound, elr, tyFrom)
 		}
-		return orb.LineString{}, err
+		return SubstringResult{}, err
 	}
-	distanceFrom := interpolateSegment(tyFrom, elrSegmentFrom.CalibrationSegments[0])
+	distanceFrom := mFrom.distance()
 
-	elrSegmentTo, err := gc.Find(elr, tyTo)
+	mTo, err := gc.match(elr, tyTo)
 	if err != nil {
 		if gc.config.VerboseOutput {
 			log.Printf(calibrationNotFound, elr, tyTo)
 		}
 
-		return orb.LineString{}, err
+		return SubstringResult{}, err
 	}
-	distanceTo := interpolateSegment(tyTo, elrSegmentTo.CalibrationSegments[0])
-
-	pts := make([]orb.Point, 0, 32) // Notional initial capacity.
-	startPt := pointAtDistanceAlongLine(distanceFrom, elrSegmentFrom.Geometry)
-	pts = append(pts, startPt)
-
-	currentDistance := 0.0
-	for i := 0; i < len(elrSegmentFrom.Geometry)-1; i++ {
-		if currentDistance > distanceFrom && currentDistance < distanceTo {
-			pts = append(pts, elrSegmentFrom.Geometry[i])
-		} else if currentDistance >= distanceTo {
-			break
+	distanceTo := mTo.distance()
+
+	line := mFrom.elr.measuredLine() // Noting that Geometry To/From are the same ELR.
+	vertices := line.verticesBetween(distanceFrom, distanceTo)
+	pts := make([]orb.Point, 0, len(vertices)+2)
+	pts = append(pts, line.pointAtExtended(distanceFrom))
+	pts = append(pts, vertices...)
+	pts = append(pts, line.pointAtExtended(distanceTo))
+
+	// Calibration segments spanned, irrespective of the order of the start and end mileages.
+	ixLow, ixHigh := min(mFrom.index, mTo.index), max(mFrom.index, mTo.index)
+	segments := make([]CalibrationSegment, ixHigh-ixLow+1)
+	copy(segments, mFrom.elr.CalibrationSegments[ixLow:ixHigh+1])
+
+	worstAccuracy := 0.0
+	for _, segment := range segments {
+		if math.Abs(segment.Accuracy) > math.Abs(worstAccuracy) {
+			worstAccuracy = segment.Accuracy
 		}
-		currentDistance += planar.Distance(elrSegmentFrom.Geometry[i], elrSegmentFrom.Geometry[i+1])
 	}
 
-	endPt := pointAtDistanceAlongLine(distanceTo, elrSegmentFrom.Geometry) // Noting that Geometry To/From are the same ELR.
-	pts = append(pts, endPt)
-	return pts, err
+	return SubstringResult{
+		Geometry:       pts,
+		AccuracyFrom:   mFrom.calib.Accuracy,
+		AccuracyTo:     mTo.calib.Accuracy,
+		WorstAccuracy:  worstAccuracy,
+		MeasuredLength: planar.Length(orb.LineString(pts)),
+		ReportedLength: math.Abs(float64(tyTo-tyFrom)) * YardsToMetres,
+		Segments:       segments,
+		AdjustmentFrom: mFrom.adjustment,
+		AdjustmentTo:   mTo.adjustment,
+		OvershootFrom:  mFrom.overshootMetres(),
+		OvershootTo:    mTo.overshootMetres(),
+	}, nil
 }
 
 // findCalibrationSegment searches for the target yardage within the calibration slice.
 func findCalibrationSegment(calibrationSegments []CalibrationSegment, tyTarget int) (CalibrationSegment, bool) {
-	var result CalibrationSegment
-
-	// Binary search; calibration slice is sorted by total yards from.
-	lo, hi := 0, len(calibrationSegments)-1
-	for lo <= hi {
-		mid := lo + (hi-lo)/2
-		if calibrationSegments[mid].TyFrom <= tyTarget && tyTarget <= calibrationSegments[mid].TyTo {
-			result = calibrationSegments[mid]
-			return result, true
-		} else if tyTarget < calibrationSegments[mid].TyFrom {
-			hi = mid - 1
-		} else {
-			lo = mid + 1
-		}
+	if i, ok := findCalibrationIndex(calibrationSegments, tyTarget); ok {
+		return calibrationSegments[i], true
 	}
 
 	// Target yardage not found in calibration slice.
-	return result, false
+	return CalibrationSegment{}, false
 }
 
 // Find searches for the calibration segment that contains the target yardage on the ELR.
-// Future enhancement may add option to "clamp" to start or end of ELR limits.
+// Mileages beyond the calibrated extent return the respective end segment, unless the lookup mode is strict.
 func (gc *Geocoder) Find(elr string, ty int) (ELR, error) {
-	e, ok := (gc.ELRs)[elr]
-	if !ok {
-		return ELR{}, fmt.Errorf("no ELR found: %s", elr)
-	}
-
-	// Search the calibration slice and return the row where total_yards_from and total_yards_to contain the target yardage.
-	calib, ok := findCalibrationSegment(e.CalibrationSegments, ty)
-	if !ok {
-		return ELR{}, fmt.Errorf("no calibration found for ELR %s at total yards %d", elr, ty)
+	m, err := gc.match(elr, ty)
+	if err != nil {
+		return ELR{}, err
 	}
 
-	e.CalibrationSegments = []CalibrationSegment{calib}
+	// Geometry is copied, as that of a memory-mapped cache is only valid until Close.
+	e := m.elr
+	e.Geometry = append(orb.LineString(nil), e.Geometry...)
+	e.Measures = append([]float64(nil), e.Measures...)
+	e.CalibrationSegments = []CalibrationSegment{m.calib}
 	return e, nil
 }
 
-// loadELRs returns the principal properties, geometry, and calibration of ELRs.
-func (gc *Geocoder) loadELRs() error {
-	if _, err := os.Stat(gc.config.CacheFn); os.IsNotExist(err) {
-		// Cache file doesn't exist, so build and serialise.
-		log.Printf("Building cache from production database")
-		if !gc.buildCache() || !gc.serialiseCache() {
-			return fmt.Errorf("failed to import data / serialise cache")
-		}
-		return nil
-	}
+// measuredLine returns the ELR geometry with its cumulative distances, computed if absent (as in hand-built ELRs).
+func (e ELR) measuredLine() measuredLine {
+	return newMeasuredLine(e.Geometry, e.Measures)
+}
 
-	if !gc.deserialiseCache() {
-		return fmt.Errorf("failed to deserialise cache")
+// mileageOutOfRange returns the error for a mileage outside of the calibrated extent of the ELR.
+func mileageOutOfRange(elr string, ty int, e ELR) error {
+	err := ErrMileageOutOfRange{ELR: elr, Ty: ty, Min: e.TyFrom, Max: e.TyTo}
+	if n := len(e.CalibrationSegments); n > 0 {
+		err.Min, err.Max = e.CalibrationSegments[0].TyFrom, e.CalibrationSegments[n-1].TyTo
 	}
 
-	return nil
+	return err
 }
 
-// buildCache reads the production database and builds the ELR cache, returning true if successful.
-func (gc *Geocoder) buildCache() bool {
+// buildCache reads the production database and builds the ELR cache.
+func (gc *Geocoder) buildCache() error {
 	prodDb, err := sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", gc.config.ProductionDbFn))
-	Check(err)
+	if err != nil {
+		return err
+	}
 	defer prodDb.Close()
 
+	return gc.importELRs(prodDb)
+}
+
+// importELRs reads all ELRs and calibration from the production database.
+func (gc *Geocoder) importELRs(prodDb *sql.DB) error {
 	const elrSQL = "SELECT elr, total_yards_from, total_yards_to, shape_length_m, l_system, geometry FROM elr"
 	elrRows, err := prodDb.Query(elrSQL)
-	Check(err)
+	if err != nil {
+		return err
+	}
 	defer elrRows.Close()
 
 	calibration := make(map[string][]CalibrationSegment, maxELRs)
 
-	const calibSQL = "SELECT elr, total_yards_from, total_yards_to, linear_offset_from_m, linear_offset_to_m, accuracy " +
+	const calibSQL = "SELECT elr, total_yards_from, total_yards_to, linear_offset_from_m, linear_offset_to_m, accuracy, method, model, slope_from, slope_to, grade " +
 		"FROM calibration ORDER BY elr, total_yards_from"
 	calibRows, err := prodDb.Query(calibSQL)
-	Check(err)
+	if err != nil {
+		return err
+	}
 	defer calibRows.Close()
 
 	for calibRows.Next() {
-		var elr string
+		var elr, method, model, grade string
 		var c CalibrationSegment
-		err := calibRows.Scan(&elr, &c.TyFrom, &c.TyTo, &c.LoFrom, &c.LoTo, &c.Accuracy)
-		Check(err)
+		if err := calibRows.Scan(&elr, &c.TyFrom, &c.TyTo, &c.LoFrom, &c.LoTo, &c.Accuracy, &method, &model, &c.SlopeFrom, &c.SlopeTo, &grade); err != nil {
+			return err
+		}
+		if c.Method, err = ParseCalibrationMethod(method); err != nil {
+			return fmt.Errorf("calibration of ELR %s: %w", elr, err)
+		}
+		if c.Model, err = ParseCalibrationModel(model); err != nil {
+			return fmt.Errorf("calibration of ELR %s: %w", elr, err)
+		}
+		if c.Grade, err = ParseCalibrationGrade(grade); err != nil {
+			return fmt.Errorf("calibration of ELR %s: %w", elr, err)
+		}
 		calibration[elr] = append(calibration[elr], c)
 	}
+	if err := calibRows.Err(); err != nil {
+		return err
+	}
 
 	gc.ELRs = make(map[string]ELR, maxELRs)
 
@@ -251,38 +407,14 @@ func (gc *Geocoder) buildCache() bool {
 		var e ELR
 		var elr string
 		var lSystem string
-		err := elrRows.Scan(&elr, &e.TyFrom, &e.TyTo, &e.ShapeLen, &lSystem, wkb.Scanner(&e.Geometry))
-		Check(err)
+		if err := elrRows.Scan(&elr, &e.TyFrom, &e.TyTo, &e.ShapeLen, &lSystem, wkb.Scanner(&e.Geometry)); err != nil {
+			return err
+		}
 		e.Metric = lSystem == "K"
+		e.Measures = cumulativeLengths(e.Geometry)
 		e.CalibrationSegments = calibration[elr]
 		gc.ELRs[elr] = e
 	}
 
-	return true
-}
-
-// serialiseCache writes the ELR cache to disk, returning true if successful.
-func (gc *Geocoder) serialiseCache() bool {
-	file, err := os.Create(gc.config.CacheFn)
-	Check(err)
-	defer file.Close()
-
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(gc.ELRs)
-	Check(err)
-
-	return true
-}
-
-// deserialise reads the ELR cache from disk, returning true if successful.
-func (gc *Geocoder) deserialiseCache() bool {
-	file, err := os.Open(gc.config.CacheFn)
-	Check(err)
-	defer file.Close()
-
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&gc.ELRs)
-	Check(err)
-
-	return true
+	return elrRows.Err()
 }
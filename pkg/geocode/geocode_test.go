@@ -1,7 +1,16 @@
 package geocode
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/paulmach/orb"
@@ -75,7 +84,7 @@ func TestPoint(t *testing.T) {
 				LoTo:     20,
 				Accuracy: 0},
 			distance_m:    0,
-			expectedPoint: RailwayPoint{orb.Point{0, 0}, 0},
+			expectedPoint: RailwayPoint{Point: orb.Point{0, 0}, Accuracy: 0},
 		},
 		{
 			geometry: orb.LineString{{0, 0}, {20, 0}},
@@ -86,7 +95,7 @@ func TestPoint(t *testing.T) {
 				LoTo:     20,
 				Accuracy: 0},
 			distance_m:    25,
-			expectedPoint: RailwayPoint{orb.Point{10, 0}, 0},
+			expectedPoint: RailwayPoint{Point: orb.Point{10, 0}, Accuracy: 0},
 		},
 		{
 			geometry: orb.LineString{{0, 0}, {20, 0}},
@@ -97,7 +106,7 @@ func TestPoint(t *testing.T) {
 				LoTo:     20,
 				Accuracy: 0},
 			distance_m:    50,
-			expectedPoint: RailwayPoint{orb.Point{20, 0}, 0},
+			expectedPoint: RailwayPoint{Point: orb.Point{20, 0}, Accuracy: 0},
 		},
 		{
 			geometry: orb.LineString{{0, 0}, {0, 20}},
@@ -108,7 +117,7 @@ func TestPoint(t *testing.T) {
 				LoTo:     20,
 				Accuracy: 0},
 			distance_m:    25,
-			expectedPoint: RailwayPoint{orb.Point{0, 10}, 0},
+			expectedPoint: RailwayPoint{Point: orb.Point{0, 10}, Accuracy: 0},
 		},
 	}
 
@@ -320,11 +329,198 @@ func TestSubstring(t *testing.T) {
 		gc.ELRs = make(map[string]ELR)
 		gc.ELRs[elrCode] = testELR
 
-		ls, _ := gc.Substring(elrCode, c.tyFrom, c.tyTo)
-		lsWKT := wkt.MarshalString(ls)
+		res, _ := gc.Substring(elrCode, c.tyFrom, c.tyTo)
+		lsWKT := wkt.MarshalString(res.Geometry)
 		if lsWKT != c.expectedWKT {
 			t.Log("error, should be:", c.expectedWKT, "but got:", lsWKT)
 			t.Fail()
 		}
 	}
 }
+
+func TestFindErrors(t *testing.T) {
+	gc := Geocoder{}
+	gc.ELRs = map[string]ELR{
+		"ABC": {
+			TyFrom: 0,
+			TyTo:   1_000,
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 10, TyTo: 500},
+				{TyFrom: 500, TyTo: 990},
+			},
+		},
+	}
+
+	_, err := gc.Find("XYZ", 100)
+	if !errors.Is(err, ErrUnknownELR) {
+		t.Errorf("expected ErrUnknownELR, but got %v", err)
+	}
+
+	_, err = gc.Point("ABC", 995)
+	var outOfRange ErrMileageOutOfRange
+	if !errors.As(err, &outOfRange) {
+		t.Fatalf("expected ErrMileageOutOfRange, but got %v", err)
+	}
+
+	expected := ErrMileageOutOfRange{ELR: "ABC", Ty: 995, Min: 10, Max: 990}
+	if outOfRange != expected {
+		t.Errorf("expected %v, but got %v", expected, outOfRange)
+	}
+}
+
+func TestDeserialiseCorruptCache(t *testing.T) {
+	cacheFn := filepath.Join(t.TempDir(), "corrupt.gob")
+	if err := os.WriteFile(cacheFn, []byte("not a gob cache"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewGeocoder(GeocoderConfig{CacheFn: cacheFn})
+	if !errors.Is(err, ErrCacheCorrupt) {
+		t.Errorf("expected ErrCacheCorrupt, but got %v", err)
+	}
+}
+
+func TestSubstringCalibrationDetail(t *testing.T) {
+	gc := Geocoder{}
+	gc.ELRs = map[string]ELR{
+		"ABC": {
+			TyFrom:   0,
+			TyTo:     1_320,
+			Geometry: orb.LineString{{0, 0}, {1_300, 0}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 0, TyTo: 440, LoFrom: 0, LoTo: 402, Accuracy: -0.336},
+				{TyFrom: 440, TyTo: 880, LoFrom: 402, LoTo: 810, Accuracy: 5.664},
+				{TyFrom: 880, TyTo: 1_320, LoFrom: 810, LoTo: 1_300, Accuracy: 87.664},
+			},
+		},
+	}
+
+	res, err := gc.Substring("ABC", 220, 660)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.AccuracyFrom != -0.336 || res.AccuracyTo != 5.664 || res.WorstAccuracy != 5.664 {
+		t.Errorf("unexpected accuracies: from %v, to %v, worst %v", res.AccuracyFrom, res.AccuracyTo, res.WorstAccuracy)
+	}
+
+	if len(res.Segments) != 2 || res.Segments[0].TyFrom != 0 || res.Segments[1].TyFrom != 440 {
+		t.Errorf("unexpected calibration segments spanned: %v", res.Segments)
+	}
+
+	if !almostEqual(res.MeasuredLength, 405) || !almostEqual(res.ReportedLength, 402.336) {
+		t.Errorf("unexpected lengths: measured %v, reported %v", res.MeasuredLength, res.ReportedLength)
+	}
+
+	res, err = gc.Substring("ABC", 1_000, 1_100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Segments) != 1 || res.WorstAccuracy != 87.664 {
+		t.Errorf("unexpected single calibration segment detail: %v, worst %v", res.Segments, res.WorstAccuracy)
+	}
+}
+
+// fixtureELRs returns a single ELR calibrated at one yard per metre.
+func fixtureELRs() map[string]ELR {
+	return map[string]ELR{
+		"ABC": {
+			TyFrom:              0,
+			TyTo:                1_000,
+			Geometry:            orb.LineString{{0, 0}, {1_000, 0}},
+			Measures:            []float64{0, 1_000},
+			CalibrationSegments: []CalibrationSegment{{TyFrom: 0, TyTo: 500, LoFrom: 0, LoTo: 500}, {TyFrom: 500, TyTo: 1_000, LoFrom: 500, LoTo: 1_000}},
+		},
+	}
+}
+
+func TestNewGeocoderFromELRs(t *testing.T) {
+	gc, err := NewGeocoderFromELRs(fixtureELRs(), GeocoderConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt, err := gc.Point("ABC", 750)
+	if err != nil || pt.Point != (orb.Point{750, 0}) {
+		t.Errorf("expected (750, 0), but got %v (%v)", pt.Point, err)
+	}
+
+	unordered := fixtureELRs()
+	e := unordered["ABC"]
+	e.CalibrationSegments[0], e.CalibrationSegments[1] = e.CalibrationSegments[1], e.CalibrationSegments[0]
+	if _, err := NewGeocoderFromELRs(unordered, GeocoderConfig{}); err == nil {
+		t.Errorf("expected error for out of order calibration segments")
+	}
+}
+
+func TestNewGeocoderFromReader(t *testing.T) {
+	for _, format := range []CacheFormat{CacheGob, CacheBinary} {
+		cacheFn := filepath.Join(t.TempDir(), "cache")
+		fixture := &Geocoder{ELRs: fixtureELRs(), config: GeocoderConfig{CacheFn: cacheFn, CacheFormat: format}}
+		if err := fixture.writeCache(cacheSource{}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(cacheFn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gc, err := NewGeocoderFromReader(bytes.NewReader(data), GeocoderConfig{CacheFormat: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gc.ELRs, fixture.ELRs) {
+			t.Errorf("format %d: expected ELRs %v, but got %v", format, fixture.ELRs, gc.ELRs)
+		}
+
+		fsys := fstest.MapFS{"data/cache": &fstest.MapFile{Data: data}}
+		if gc, err = NewGeocoderFromFS(fsys, "data/cache", GeocoderConfig{CacheFormat: format}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gc.ELRs, fixture.ELRs) {
+			t.Errorf("format %d: expected ELRs from FS %v, but got %v", format, fixture.ELRs, gc.ELRs)
+		}
+
+		if _, err := NewGeocoderFromReader(strings.NewReader("not a cache"), GeocoderConfig{CacheFormat: format}); !errors.Is(err, ErrCacheCorrupt) {
+			t.Errorf("format %d: expected ErrCacheCorrupt, but got %v", format, err)
+		}
+	}
+
+	if _, err := NewGeocoderFromFS(fstest.MapFS{}, "missing", GeocoderConfig{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, but got %v", err)
+	}
+}
+
+func TestNewGeocoderFromDB(t *testing.T) {
+	dbFn := filepath.Join(t.TempDir(), "production.db")
+	writeTestProductionDb(t, dbFn, "1.0.0", 1_000)
+
+	db, err := sql.Open("sqlite3", dbFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, lazy := range []bool{false, true} {
+		gc, err := NewGeocoderFromDB(db, GeocoderConfig{LazyLoad: lazy})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pt, err := gc.Point("ABC", 400)
+		if err != nil || pt.Point != (orb.Point{400, 0}) {
+			t.Errorf("lazy %t: expected (400, 0), but got %v (%v)", lazy, pt.Point, err)
+		}
+
+		if err := gc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Caller's database remains open.
+		if err := db.Ping(); err != nil {
+			t.Errorf("lazy %t: expected database to remain open, but got %v", lazy, err)
+		}
+	}
+}
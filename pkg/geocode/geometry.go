@@ -4,6 +4,7 @@ package geocode
 
 import (
 	"math"
+	"sort"
 
 	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/planar"
@@ -18,29 +19,135 @@ func interpolatePoint(point1, point2 orb.Point, ratio float64) orb.Point {
 
 // NearestPointOnLine returns the nearest point on the line to the point, and the distance to that point.
 func NearestPointOnLine(line *orb.LineString, point orb.Point) (orb.Point, float64) {
+	_, nearestPoint, minDistance := nearestSegmentOnLine(*line, point)
+	return nearestPoint, minDistance
+}
+
+// nearestSegmentOnLine returns the index of the line segment nearest to the point,
+// the nearest point on that segment, and the distance to that point.
+func nearestSegmentOnLine(line orb.LineString, point orb.Point) (int, orb.Point, float64) {
 	var (
+		nearestIndex int
 		nearestPoint orb.Point
 		minDistance  = math.MaxFloat64
 	)
 
-	for i := 0; i < len(*line)-1; i++ {
-		start := (*line)[i]
-		end := (*line)[i+1]
-
-		np, distance := nearestPointOnSegment(start, end, point)
+	for i := 0; i < len(line)-1; i++ {
+		np, distance := nearestPointOnSegment(line[i], line[i+1], point)
 
 		if distance < minDistance {
 			minDistance = distance
 			nearestPoint = np
+			nearestIndex = i
 		}
 	}
 
-	return nearestPoint, minDistance
+	return nearestIndex, nearestPoint, minDistance
+}
+
+// LineLocation represents a point projected onto the nearest segment of a linestring.
+type LineLocation struct {
+	Segment  int       // Index of the segment start point within the linestring.
+	Fraction float64   // Fractional position of the projected point along the segment (0 to 1).
+	Measure  float64   // Distance along the linestring from its start to the projected point (metres).
+	Point    orb.Point // Projected (nearest) point on the linestring.
+	Distance float64   // Perpendicular distance from the point to the linestring (metres).
+	Side     Side      // Side of the linestring the point lies, looking in the direction of the linestring.
+}
+
+// LocateOnLine projects the point onto the nearest segment of the linestring, returning the segment, measure,
+// distance and side directly from the projection. Returns false if the linestring has fewer than two points.
+func LocateOnLine(line orb.LineString, point orb.Point) (LineLocation, bool) {
+	return newMeasuredLine(line, nil).locate(point)
+}
+
+// LocateOnLineWithin projects the point onto the nearest part of the linestring lying between the two measures
+// (metres along the linestring), so that a line which doubles back on itself is searched only in the expected
+// portion. Returns false if the linestring has fewer than two points or the measures do not overlap the linestring.
+func LocateOnLineWithin(line orb.LineString, point orb.Point, measureFrom, measureTo float64) (LineLocation, bool) {
+	return newMeasuredLine(line, nil).locateWithin(point, measureFrom, measureTo)
+}
+
+// locate projects the point onto the nearest segment of the measured linestring.
+func (ml measuredLine) locate(point orb.Point) (LineLocation, bool) {
+	if len(ml.line) < 2 {
+		return LineLocation{}, false
+	}
+
+	i, _, _ := nearestSegmentOnLine(ml.line, point)
+	fraction, nearestPoint, distance := projectOntoSegment(ml.line[i], ml.line[i+1], point)
+	return ml.location(point, i, fraction, nearestPoint, distance), true
+}
+
+// locateWithin projects the point onto the nearest part of the measured linestring between the two measures.
+func (ml measuredLine) locateWithin(point orb.Point, measureFrom, measureTo float64) (LineLocation, bool) {
+	if len(ml.line) < 2 {
+		return LineLocation{}, false
+	}
+
+	measureFrom, measureTo = max(measureFrom, 0), min(measureTo, ml.length())
+	if measureFrom > measureTo {
+		return LineLocation{}, false
+	}
+
+	var (
+		nearestIndex    = -1
+		nearestFraction float64
+		nearestPoint    orb.Point
+		minDistance     = math.MaxFloat64
+	)
+
+	for i := max(ml.search(measureFrom)-1, 0); i < len(ml.line)-1 && ml.measures[i] <= measureTo; i++ {
+		segmentLength := ml.measures[i+1] - ml.measures[i]
+		if segmentLength == 0 || ml.measures[i+1] < measureFrom {
+			continue
+		}
+
+		// Limit the projection to the part of the segment within the measures.
+		fraction, _, _ := projectOntoSegment(ml.line[i], ml.line[i+1], point)
+		fraction = max(fraction, (measureFrom-ml.measures[i])/segmentLength)
+		fraction = min(fraction, (measureTo-ml.measures[i])/segmentLength)
+		np := interpolatePoint(ml.line[i], ml.line[i+1], fraction)
+
+		if distance := planar.Distance(np, point); distance < minDistance {
+			nearestIndex, nearestFraction, nearestPoint, minDistance = i, fraction, np, distance
+		}
+	}
+
+	if nearestIndex < 0 {
+		return LineLocation{}, false
+	}
+
+	return ml.location(point, nearestIndex, nearestFraction, nearestPoint, minDistance), true
+}
+
+// location returns the location of the point projected at the fractional position along the given segment.
+func (ml measuredLine) location(point orb.Point, i int, fraction float64, nearestPoint orb.Point, distance float64) LineLocation {
+	side := SideOn
+	if distance > 0 {
+		side = sideOfSegment(ml.line[i], ml.line[i+1], point)
+	}
+
+	return LineLocation{
+		Segment:  i,
+		Fraction: fraction,
+		Measure:  ml.measures[i] + fraction*(ml.measures[i+1]-ml.measures[i]),
+		Point:    nearestPoint,
+		Distance: distance,
+		Side:     side,
+	}
 }
 
 // nearestPointOnSegment returns the nearest point on the line segment defined by the start and end points,
 // and the distance the point is from the start of the line segment.
 func nearestPointOnSegment(segmentStartPoint, segmentEndPoint, targetPoint orb.Point) (orb.Point, float64) {
+	_, nearestPoint, distance := projectOntoSegment(segmentStartPoint, segmentEndPoint, targetPoint)
+	return nearestPoint, distance
+}
+
+// projectOntoSegment returns the fractional position (0 to 1) along the line segment defined by the start and end
+// points of the nearest point on the segment to the target point, the nearest point, and the distance to it.
+func projectOntoSegment(segmentStartPoint, segmentEndPoint, targetPoint orb.Point) (float64, orb.Point, float64) {
 	// Calculate the differences in x and y co-ordinates for the start and end points of the line segment.
 	deltaX := segmentEndPoint[0] - segmentStartPoint[0]
 	deltaY := segmentEndPoint[1] - segmentStartPoint[1]
@@ -54,7 +161,7 @@ func nearestPointOnSegment(segmentStartPoint, segmentEndPoint, targetPoint orb.P
 
 	// If the length is zero (start and end are the same), return the start point and the distance to the target point.
 	if lenSquared == 0.0 {
-		return segmentStartPoint, math.Sqrt(deltaXFromStart*deltaXFromStart + deltaYFromStart*deltaYFromStart)
+		return 0, segmentStartPoint, math.Sqrt(deltaXFromStart*deltaXFromStart + deltaYFromStart*deltaYFromStart)
 	}
 
 	// Calculate the projection of the point onto the line segment.
@@ -62,12 +169,12 @@ func nearestPointOnSegment(segmentStartPoint, segmentEndPoint, targetPoint orb.P
 
 	// If the projection falls before the start of the line segment, return the start point and the distance to the target point.
 	if projectionRatio < 0 {
-		return segmentStartPoint, math.Sqrt(deltaXFromStart*deltaXFromStart + deltaYFromStart*deltaYFromStart)
+		return 0, segmentStartPoint, math.Sqrt(deltaXFromStart*deltaXFromStart + deltaYFromStart*deltaYFromStart)
 	} else if projectionRatio > 1 {
 		// If the projection falls after the end of the line segment, return the end point and the distance to the target point.
 		deltaX = targetPoint[0] - segmentEndPoint[0]
 		deltaY = targetPoint[1] - segmentEndPoint[1]
-		return segmentEndPoint, math.Sqrt(deltaX*deltaX + deltaY*deltaY)
+		return 1, segmentEndPoint, math.Sqrt(deltaX*deltaX + deltaY*deltaY)
 	}
 
 	// If the projection falls on the line segment, calculate the co-ordinates of the projection point.
@@ -78,61 +185,213 @@ func nearestPointOnSegment(segmentStartPoint, segmentEndPoint, targetPoint orb.P
 	deltaY = targetPoint[1] - projection[1]
 
 	// Return the projection point and the distance to the target point.
-	return projection, math.Sqrt(deltaX*deltaX + deltaY*deltaY)
+	return projectionRatio, projection, math.Sqrt(deltaX*deltaX + deltaY*deltaY)
 }
 
-// DistanceAlongLine returns the distance along the line from the start to the point.
+// DistanceAlongLine returns the distance along the line from the start to the point, projected onto the nearest
+// segment. Returns zero if the line has fewer than two points. Equivalent to the measure of LocateOnLine.
 func DistanceAlongLine(line *orb.LineString, point orb.Point) float64 {
-	totalDistance := 0.0
+	loc, _ := LocateOnLine(*line, point)
+	return loc.Measure
+}
 
-	for i := 0; i < len(*line)-1; i++ {
-		start, end := (*line)[i], (*line)[i+1]
+// sideOfSegment returns which side of the directed line segment the point lies.
+func sideOfSegment(segmentStartPoint, segmentEndPoint, targetPoint orb.Point) Side {
+	cross := (segmentEndPoint[0]-segmentStartPoint[0])*(targetPoint[1]-segmentStartPoint[1]) -
+		(segmentEndPoint[1]-segmentStartPoint[1])*(targetPoint[0]-segmentStartPoint[0])
 
-		if pointOnLineSegment(start, end, point) {
-			totalDistance += planar.Distance(start, point)
-			break
-		} else {
-			totalDistance += planar.Distance(start, end)
-		}
+	switch {
+	case cross > 0:
+		return SideLeft
+	case cross < 0:
+		return SideRight
+	default:
+		return SideOn
+	}
+}
+
+// cumulativeLengths returns the planar distance (metres) along the linestring at each vertex.
+func cumulativeLengths(line orb.LineString) []float64 {
+	if len(line) == 0 {
+		return nil
+	}
+
+	measures := make([]float64, len(line))
+	for i := 1; i < len(line); i++ {
+		measures[i] = measures[i-1] + planar.Distance(line[i-1], line[i])
 	}
 
-	return totalDistance
+	return measures
 }
 
-// pointOnLineSegment returns true if the point is on the line segment defined by the start and end points.
-func pointOnLineSegment(startPoint, endPoint, targetPoint orb.Point) bool {
-	const Epsilon = 1e-9
-	segmentLength := planar.Distance(startPoint, endPoint)
-	distanceToSegmentStart := planar.Distance(startPoint, targetPoint)
-	distanceToSegmentEnd := planar.Distance(endPoint, targetPoint)
-	return math.Abs(segmentLength-(distanceToSegmentStart+distanceToSegmentEnd)) < Epsilon
+// measuredLine represents a linestring with the cumulative distance at each vertex, so that positions along
+// the line are found by binary search rather than by walking the line.
+type measuredLine struct {
+	line     orb.LineString // Linestring of at least one point.
+	measures []float64      // Distance (metres) along the linestring at each vertex.
 }
 
-// pointAtDistanceAlongLine returns the point (interpolated if necessary) at the given distance (metres) along a linestring.
-// The orb library does not have this function, so this is a rework of the library's spherical equivalent.
-func pointAtDistanceAlongLine(distance float64, line orb.LineString) orb.Point {
-	numPoints := len(line)
+// newMeasuredLine returns the measured linestring, computing the measures if not provided.
+func newMeasuredLine(line orb.LineString, measures []float64) measuredLine {
+	if len(measures) != len(line) {
+		measures = cumulativeLengths(line)
+	}
+
+	return measuredLine{line: line, measures: measures}
+}
+
+// length returns the length (metres) of the linestring.
+func (ml measuredLine) length() float64 {
+	return ml.measures[len(ml.measures)-1]
+}
+
+// search returns the index of the first vertex beyond the given distance (metres), or the number of vertices if none.
+func (ml measuredLine) search(distance float64) int {
+	return sort.Search(len(ml.measures), func(i int) bool { return ml.measures[i] > distance })
+}
+
+// pointAt returns the point (interpolated if necessary) at the given distance (metres) along the linestring,
+// limited to the start and end points.
+func (ml measuredLine) pointAt(distance float64) orb.Point {
+	i := ml.search(distance)
+	switch {
+	case i == 0:
+		return ml.line[0]
+	case i == len(ml.line):
+		return ml.line[len(ml.line)-1]
+	}
 
-	if distance < 0 || numPoints == 1 {
+	// Vertex i is strictly beyond the distance, so the segment is not degenerate.
+	ratio := (distance - ml.measures[i-1]) / (ml.measures[i] - ml.measures[i-1])
+	return interpolatePoint(ml.line[i-1], ml.line[i], ratio)
+}
+
+// pointAtExtended returns the point at the given distance (metres) along the linestring, continuing along the
+// tangent of the first or last segment for distances before the start or beyond the end of the linestring.
+func (ml measuredLine) pointAtExtended(distance float64) orb.Point {
+	line := ml.line
+	if distance < 0 {
+		for i := 1; i < len(line); i++ {
+			if segmentLength := planar.Distance(line[0], line[i]); segmentLength > 0 {
+				return interpolatePoint(line[0], line[i], distance/segmentLength)
+			}
+		}
 		return line[0]
 	}
 
-	var (
-		travelled          = 0.0
-		fromPoint, toPoint orb.Point
-	)
+	last := len(line) - 1
+	if excess := distance - ml.length(); excess > 0 {
+		for i := last - 1; i >= 0; i-- {
+			if segmentLength := planar.Distance(line[i], line[last]); segmentLength > 0 {
+				return interpolatePoint(line[last], line[i], -excess/segmentLength)
+			}
+		}
+		return line[last]
+	}
+
+	return ml.pointAt(distance)
+}
+
+// segmentAt returns the index of the (non-degenerate) segment start point that the given distance (metres)
+// along the linestring falls within, consistent with pointAt. Distances before the start or beyond the end
+// return the first or last non-degenerate segment respectively.
+func (ml measuredLine) segmentAt(distance float64) (int, bool) {
+	i := ml.search(distance)
+	if i > 0 && i < len(ml.line) {
+		return i - 1, true
+	}
+
+	if i == 0 {
+		// Before the start, so the first non-degenerate segment.
+		for j := 1; j < len(ml.measures); j++ {
+			if ml.measures[j] > ml.measures[j-1] {
+				return j - 1, true
+			}
+		}
+		return -1, false
+	}
+
+	// Beyond the end, so the last non-degenerate segment.
+	for j := len(ml.measures) - 1; j > 0; j-- {
+		if ml.measures[j] > ml.measures[j-1] {
+			return j - 1, true
+		}
+	}
+	return -1, false
+}
+
+// verticesBetween returns the vertices lying strictly between the two distances (metres) along the linestring,
+// excluding the final vertex, in order of increasing distance. No vertices are returned if the distances are reversed.
+func (ml measuredLine) verticesBetween(distanceFrom, distanceTo float64) orb.LineString {
+	from := ml.search(distanceFrom)
+	to := sort.Search(len(ml.measures), func(i int) bool { return ml.measures[i] >= distanceTo })
+	to = min(to, len(ml.line)-1)
+	if from >= to {
+		return nil
+	}
+
+	return ml.line[from:to]
+}
+
+// gridBearing returns the bearing (degrees clockwise from grid north, 0 to 360) from the first point to the second.
+func gridBearing(fromPoint, toPoint orb.Point) float64 {
+	bearing := math.Atan2(toPoint[0]-fromPoint[0], toPoint[1]-fromPoint[1]) * degreesPerRadian
+	if bearing < 0 {
+		bearing += bearingFullRotation
+	}
+
+	return bearing
+}
+
+// offsetMitreLimit is the maximum ratio of mitre length to offset distance before a join is bevelled.
+const offsetMitreLimit = 5.0
+
+// leftNormal returns the unit vector perpendicular to the left of the directed line segment.
+func leftNormal(segmentStartPoint, segmentEndPoint orb.Point) orb.Point {
+	length := planar.Distance(segmentStartPoint, segmentEndPoint)
+	return orb.Point{-(segmentEndPoint[1] - segmentStartPoint[1]) / length, (segmentEndPoint[0] - segmentStartPoint[0]) / length}
+}
+
+// offsetLine returns the linestring offset parallel by the given distance (metres) to the left of the line direction
+// (negative distances to the right), with mitred joins at vertices, bevelled where the mitre would be excessively long.
+func offsetLine(line orb.LineString, offset float64) orb.LineString {
+	// Repeated points have no direction, so are removed.
+	pts := make(orb.LineString, 0, len(line))
+	for _, pt := range line {
+		if len(pts) == 0 || pts[len(pts)-1] != pt {
+			pts = append(pts, pt)
+		}
+	}
+
+	if len(pts) < 2 {
+		return pts
+	}
+
+	normals := make([]orb.Point, len(pts)-1)
+	for i := range normals {
+		normals[i] = leftNormal(pts[i], pts[i+1])
+	}
+
+	result := make(orb.LineString, 0, len(pts)+4) // Notional capacity for bevelled joins.
+	result = append(result, orb.Point{pts[0][0] + normals[0][0]*offset, pts[0][1] + normals[0][1]*offset})
 
-	for i := 1; i < numPoints; i++ {
-		fromPoint, toPoint = line[i-1], line[i]
-		actualSegmentDistance := planar.Distance(fromPoint, toPoint)
-		expectedSegmentDistance := distance - travelled
+	for i := 1; i < len(pts)-1; i++ {
+		before, after := normals[i-1], normals[i]
+		mitre := orb.Point{before[0] + after[0], before[1] + after[1]}
+		cosHalfAngle := (mitre[0]*after[0] + mitre[1]*after[1]) / math.Hypot(mitre[0], mitre[1])
 
-		if expectedSegmentDistance < actualSegmentDistance {
-			return interpolatePoint(fromPoint, toPoint, expectedSegmentDistance/actualSegmentDistance)
+		if cosHalfAngle < 1/offsetMitreLimit || math.IsNaN(cosHalfAngle) {
+			// Sharp turn, so bevel the join with the offset points of either segment.
+			result = append(result,
+				orb.Point{pts[i][0] + before[0]*offset, pts[i][1] + before[1]*offset},
+				orb.Point{pts[i][0] + after[0]*offset, pts[i][1] + after[1]*offset})
+			continue
 		}
 
-		travelled += actualSegmentDistance
+		scale := offset / cosHalfAngle / math.Hypot(mitre[0], mitre[1])
+		result = append(result, orb.Point{pts[i][0] + mitre[0]*scale, pts[i][1] + mitre[1]*scale})
 	}
 
-	return toPoint
+	last, normal := pts[len(pts)-1], normals[len(normals)-1]
+	return append(result, orb.Point{last[0] + normal[0]*offset, last[1] + normal[1]*offset})
 }
@@ -2,6 +2,7 @@ package geocode
 
 import (
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/paulmach/orb"
@@ -227,48 +228,6 @@ func TestNearestPointOnSegment(t *testing.T) {
 	}
 }
 
-func TestPointOnLineSegment(t *testing.T) {
-	tests := []struct {
-		name        string
-		startPoint  orb.Point
-		endPoint    orb.Point
-		targetPoint orb.Point
-		expectedHit bool
-	}{
-		{
-			name:        "Test 1",
-			startPoint:  orb.Point{0, 0},
-			endPoint:    orb.Point{1, 1},
-			targetPoint: orb.Point{0.5, 0.5},
-			expectedHit: true,
-		},
-		{
-			name:        "Test 2",
-			startPoint:  orb.Point{0, 0},
-			endPoint:    orb.Point{1, 1},
-			targetPoint: orb.Point{1, 1},
-			expectedHit: true,
-		},
-		{
-			name:        "Test 3",
-			startPoint:  orb.Point{0, 0},
-			endPoint:    orb.Point{1, 1},
-			targetPoint: orb.Point{2, 2},
-			expectedHit: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := pointOnLineSegment(tt.startPoint, tt.endPoint, tt.targetPoint)
-
-			if got != tt.expectedHit {
-				t.Errorf("Expected %v, got %v", tt.expectedHit, got)
-			}
-		})
-	}
-}
-
 func TestDistanceAlongLine(t *testing.T) {
 	line := &orb.LineString{{0, 0}, {0, 1}, {1, 1}}
 
@@ -297,6 +256,16 @@ func TestDistanceAlongLine(t *testing.T) {
 			point:            orb.Point{1, 1},
 			expectedDistance: 2,
 		},
+		{
+			name:             "Off the line",
+			point:            orb.Point{0.25, 2},
+			expectedDistance: 1.25,
+		},
+		{
+			name:             "Beyond the end",
+			point:            orb.Point{3, 1},
+			expectedDistance: 2,
+		},
 	}
 
 	for _, c := range cases {
@@ -313,7 +282,7 @@ func almostEqual(a, b float64) bool {
 	return math.Abs(a-b) <= Epsilon
 }
 
-func TestPointAtDistanceAlongLine(t *testing.T) {
+func TestMeasuredLinePointAt(t *testing.T) {
 	cases := []struct {
 		name          string
 		line          orb.LineString
@@ -373,7 +342,7 @@ func TestPointAtDistanceAlongLine(t *testing.T) {
 	const Epsilon = 1e-6
 
 	for _, c := range cases {
-		gotPoint := pointAtDistanceAlongLine(c.distance, c.line)
+		gotPoint := newMeasuredLine(c.line, nil).pointAt(c.distance)
 		deltaX := gotPoint.X() - c.expectedPoint.X()
 		deltaY := gotPoint.Y() - c.expectedPoint.Y()
 		if math.Abs(deltaX) > Epsilon || math.Abs(deltaY) > Epsilon {
@@ -383,3 +352,197 @@ func TestPointAtDistanceAlongLine(t *testing.T) {
 	}
 
 }
+
+func TestSideOfSegment(t *testing.T) {
+	cases := []struct {
+		name     string
+		start    orb.Point
+		end      orb.Point
+		point    orb.Point
+		expected Side
+	}{
+		{name: "Left of eastbound", start: orb.Point{0, 0}, end: orb.Point{10, 0}, point: orb.Point{5, 1}, expected: SideLeft},
+		{name: "Right of eastbound", start: orb.Point{0, 0}, end: orb.Point{10, 0}, point: orb.Point{5, -1}, expected: SideRight},
+		{name: "On eastbound", start: orb.Point{0, 0}, end: orb.Point{10, 0}, point: orb.Point{5, 0}, expected: SideOn},
+		{name: "Left of southbound", start: orb.Point{0, 10}, end: orb.Point{0, 0}, point: orb.Point{1, 5}, expected: SideLeft},
+		{name: "Right of southbound", start: orb.Point{0, 10}, end: orb.Point{0, 0}, point: orb.Point{-1, 5}, expected: SideRight},
+	}
+
+	for _, c := range cases {
+		got := sideOfSegment(c.start, c.end, c.point)
+		if got != c.expected {
+			t.Errorf("%s: expected %v, but got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestMeasuredLinePointAtExtended(t *testing.T) {
+	cases := []struct {
+		name          string
+		line          orb.LineString
+		distance      float64
+		expectedPoint orb.Point
+	}{
+		{name: "Within", line: orb.LineString{{0, 0}, {10, 0}, {10, 10}}, distance: 15, expectedPoint: orb.Point{10, 5}},
+		{name: "Before start", line: orb.LineString{{0, 0}, {3, 4}, {10, 10}}, distance: -5, expectedPoint: orb.Point{-3, -4}},
+		{name: "Beyond end", line: orb.LineString{{0, 0}, {10, 0}, {10, 10}}, distance: 25, expectedPoint: orb.Point{10, 15}},
+		{name: "Repeated end point", line: orb.LineString{{0, 0}, {10, 0}, {10, 0}}, distance: 12, expectedPoint: orb.Point{12, 0}},
+		{name: "Single point", line: orb.LineString{{7, 7}}, distance: 12, expectedPoint: orb.Point{7, 7}},
+	}
+
+	const Epsilon = 1e-9
+
+	for _, c := range cases {
+		got := newMeasuredLine(c.line, nil).pointAtExtended(c.distance)
+		if math.Abs(got.X()-c.expectedPoint.X()) > Epsilon || math.Abs(got.Y()-c.expectedPoint.Y()) > Epsilon {
+			t.Errorf("%s: expected %v, but got %v", c.name, c.expectedPoint, got)
+		}
+	}
+}
+
+func TestCumulativeLengths(t *testing.T) {
+	got := cumulativeLengths(orb.LineString{{0, 0}, {3, 4}, {3, 4}, {3, 10}})
+	expected := []float64{0, 5, 5, 11}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, but got %v", expected, got)
+	}
+
+	if cumulativeLengths(nil) != nil {
+		t.Errorf("expected nil measures for empty linestring")
+	}
+}
+
+func TestMeasuredLineSegmentAt(t *testing.T) {
+	line := newMeasuredLine(orb.LineString{{0, 0}, {0, 0}, {10, 0}, {10, 0}, {10, 10}, {10, 10}}, nil)
+
+	cases := []struct {
+		distance float64
+		expected int
+	}{
+		{-5, 1},
+		{0, 1},
+		{5, 1},
+		{10, 3},
+		{15, 3},
+		{20, 3},
+		{25, 3},
+	}
+
+	for _, c := range cases {
+		got, ok := line.segmentAt(c.distance)
+		if !ok || got != c.expected {
+			t.Errorf("segmentAt(%v): expected %d, but got %d (%t)", c.distance, c.expected, got, ok)
+		}
+	}
+
+	if _, ok := newMeasuredLine(orb.LineString{{1, 1}, {1, 1}}, nil).segmentAt(0); ok {
+		t.Errorf("expected no segment for degenerate linestring")
+	}
+}
+
+func TestMeasuredLineVerticesBetween(t *testing.T) {
+	line := newMeasuredLine(orb.LineString{{0, 0}, {10, 0}, {20, 0}, {30, 0}}, nil)
+
+	cases := []struct {
+		from, to float64
+		expected orb.LineString
+	}{
+		{5, 25, orb.LineString{{10, 0}, {20, 0}}},
+		{10, 20, nil},
+		{9, 21, orb.LineString{{10, 0}, {20, 0}}},
+		{-5, 5, orb.LineString{{0, 0}}},
+		{25, 40, nil}, // Final vertex is excluded.
+		{25, 5, nil},
+	}
+
+	for _, c := range cases {
+		got := line.verticesBetween(c.from, c.to)
+		if len(got) != len(c.expected) || (len(got) > 0 && !reflect.DeepEqual(got, c.expected)) {
+			t.Errorf("verticesBetween(%v, %v): expected %v, but got %v", c.from, c.to, c.expected, got)
+		}
+	}
+}
+
+func TestLocateOnLine(t *testing.T) {
+	line := orb.LineString{{0, 0}, {10, 0}, {10, 10}}
+
+	cases := []struct {
+		name     string
+		line     orb.LineString
+		point    orb.Point
+		expected LineLocation
+	}{
+		{"Left of first segment", line, orb.Point{4, 3}, LineLocation{Segment: 0, Fraction: 0.4, Measure: 4, Point: orb.Point{4, 0}, Distance: 3, Side: SideLeft}},
+		{"Right of second segment", line, orb.Point{12, 5}, LineLocation{Segment: 1, Fraction: 0.5, Measure: 15, Point: orb.Point{10, 5}, Distance: 2, Side: SideRight}},
+		{"On line", line, orb.Point{10, 2.5}, LineLocation{Segment: 1, Fraction: 0.25, Measure: 12.5, Point: orb.Point{10, 2.5}, Distance: 0, Side: SideOn}},
+		{"Before start", line, orb.Point{-3, 4}, LineLocation{Segment: 0, Fraction: 0, Measure: 0, Point: orb.Point{0, 0}, Distance: 5, Side: SideLeft}},
+		{"Beyond end", line, orb.Point{10, 13}, LineLocation{Segment: 1, Fraction: 1, Measure: 20, Point: orb.Point{10, 10}, Distance: 3, Side: SideOn}},
+		{
+			"Large co-ordinates",
+			orb.LineString{{651_234.567, 1_201_234.567}, {651_334.567, 1_201_334.567}, {651_434.567, 1_201_334.567}},
+			orb.Point{651_384.567, 1_201_335.567},
+			LineLocation{Segment: 1, Fraction: 0.5, Measure: 100*math.Sqrt2 + 50, Point: orb.Point{651_384.567, 1_201_334.567}, Distance: 1, Side: SideLeft},
+		},
+	}
+
+	for _, c := range cases {
+		got, ok := LocateOnLine(c.line, c.point)
+		if !ok {
+			t.Errorf("%s: expected location", c.name)
+			continue
+		}
+
+		e := c.expected
+		if got.Segment != e.Segment || got.Side != e.Side || !almostEqual(got.Fraction, e.Fraction) ||
+			math.Abs(got.Measure-e.Measure) > 1e-6 || math.Abs(got.Distance-e.Distance) > 1e-6 ||
+			math.Abs(got.Point.X()-e.Point.X()) > 1e-6 || math.Abs(got.Point.Y()-e.Point.Y()) > 1e-6 {
+			t.Errorf("%s: expected %+v, but got %+v", c.name, e, got)
+		}
+	}
+
+	if _, ok := LocateOnLine(orb.LineString{{1, 1}}, orb.Point{0, 0}); ok {
+		t.Errorf("expected no location on single point linestring")
+	}
+}
+
+func TestLocateOnLineWithin(t *testing.T) {
+	// Hairpin, returning alongside the outward segment.
+	line := orb.LineString{{0, 0}, {100, 0}, {100, 10}, {0, 10}}
+
+	cases := []struct {
+		name        string
+		point       orb.Point
+		measureFrom float64
+		measureTo   float64
+		expected    LineLocation
+	}{
+		{"Outward within window", orb.Point{20, 6}, 0, 50, LineLocation{Segment: 0, Fraction: 0.2, Measure: 20, Point: orb.Point{20, 0}, Distance: 6, Side: SideLeft}},
+		{"Return within window", orb.Point{20, 6}, 150, 210, LineLocation{Segment: 2, Fraction: 0.8, Measure: 190, Point: orb.Point{20, 10}, Distance: 4, Side: SideLeft}},
+		{"Limited to window end", orb.Point{80, 0}, 0, 50, LineLocation{Segment: 0, Fraction: 0.5, Measure: 50, Point: orb.Point{50, 0}, Distance: 30, Side: SideOn}},
+		{"Limited to window start", orb.Point{10, 0}, 30, 60, LineLocation{Segment: 0, Fraction: 0.3, Measure: 30, Point: orb.Point{30, 0}, Distance: 20, Side: SideOn}},
+		{"Window beyond line", orb.Point{10, 12}, 200, 500, LineLocation{Segment: 2, Fraction: 0.9, Measure: 200, Point: orb.Point{10, 10}, Distance: 2, Side: SideRight}},
+	}
+
+	for _, c := range cases {
+		got, ok := LocateOnLineWithin(line, c.point, c.measureFrom, c.measureTo)
+		if !ok {
+			t.Errorf("%s: expected location", c.name)
+			continue
+		}
+
+		e := c.expected
+		if got.Segment != e.Segment || got.Side != e.Side || !almostEqual(got.Fraction, e.Fraction) ||
+			math.Abs(got.Measure-e.Measure) > 1e-6 || math.Abs(got.Distance-e.Distance) > 1e-6 ||
+			math.Abs(got.Point.X()-e.Point.X()) > 1e-6 || math.Abs(got.Point.Y()-e.Point.Y()) > 1e-6 {
+			t.Errorf("%s: expected %+v, but got %+v", c.name, e, got)
+		}
+	}
+
+	if _, ok := LocateOnLineWithin(line, orb.Point{0, 0}, 50, 40); ok {
+		t.Errorf("expected no location for reversed measures")
+	}
+
+	if _, ok := LocateOnLineWithin(line, orb.Point{0, 0}, 250, 300); ok {
+		t.Errorf("expected no location for measures beyond the line")
+	}
+}
@@ -0,0 +1,197 @@
+// Lazy loading of ELR geometry and calibration from the production database on first use.
+
+package geocode
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkb"
+)
+
+const defaultMaxResidentELRs = 100 // Default maximum number of ELRs held in memory when lazy loading.
+
+// lazyLoader represents the production database connection and resident ELRs of a lazy loading Geocoder.
+type lazyLoader struct {
+	db        *sql.DB   // Production database (read only).
+	ownsDb    bool      // Production database was opened by the loader, so is closed with it.
+	geomStmt  *sql.Stmt // Selects the geometry of an ELR.
+	calibStmt *sql.Stmt // Selects the calibration segments of an ELR.
+	resident  *elrLRU   // ELRs with geometry and calibration loaded.
+}
+
+// openLazy opens the production database and loads the extents (without geometry or calibration) of all ELRs.
+func (gc *Geocoder) openLazy() error {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro", gc.config.ProductionDbFn))
+	if err != nil {
+		return err
+	}
+
+	if err := gc.openLazyDB(db, true); err != nil {
+		db.Close()
+		return err
+	}
+
+	return nil
+}
+
+// openLazyDB loads the extents (without geometry or calibration) of all ELRs from the production database.
+func (gc *Geocoder) openLazyDB(db *sql.DB, ownsDb bool) error {
+	l := &lazyLoader{db: db, ownsDb: ownsDb}
+	if err := l.prepare(); err != nil {
+		return err
+	}
+
+	elrs, err := l.loadExtents()
+	if err != nil {
+		l.close()
+		return err
+	}
+
+	capacity := gc.config.MaxResidentELRs
+	if capacity <= 0 {
+		capacity = defaultMaxResidentELRs
+	}
+	l.resident = newELRLRU(capacity)
+
+	gc.ELRs, gc.lazy = elrs, l
+	return nil
+}
+
+// prepare prepares the per-ELR geometry and calibration statements.
+func (l *lazyLoader) prepare() error {
+	var err error
+	if l.geomStmt, err = l.db.Prepare("SELECT geometry FROM elr WHERE elr = ?"); err != nil {
+		return err
+	}
+
+	const calibSQL = "SELECT total_yards_from, total_yards_to, linear_offset_from_m, linear_offset_to_m, accuracy, method, model, slope_from, slope_to, grade " +
+		"FROM calibration WHERE elr = ? ORDER BY total_yards_from"
+	if l.calibStmt, err = l.db.Prepare(calibSQL); err != nil {
+		l.geomStmt.Close()
+		return err
+	}
+
+	return nil
+}
+
+// loadExtents returns all ELRs with their reported extents, but without geometry or calibration.
+func (l *lazyLoader) loadExtents() (map[string]ELR, error) {
+	rows, err := l.db.Query("SELECT elr, total_yards_from, total_yards_to, shape_length_m, l_system FROM elr")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	elrs := make(map[string]ELR, maxELRs)
+	for rows.Next() {
+		var e ELR
+		var elr, lSystem string
+		if err := rows.Scan(&elr, &e.TyFrom, &e.TyTo, &e.ShapeLen, &lSystem); err != nil {
+			return nil, err
+		}
+		e.Metric = lSystem == "K"
+		elrs[elr] = e
+	}
+
+	return elrs, rows.Err()
+}
+
+// load returns the ELR extent with its geometry and calibration read from the production database.
+func (l *lazyLoader) load(elr string, e ELR) (ELR, error) {
+	if err := l.geomStmt.QueryRow(elr).Scan(wkb.Scanner(&e.Geometry)); err != nil {
+		return ELR{}, fmt.Errorf("failed to load geometry of ELR %s: %w", elr, err)
+	}
+	e.Measures = cumulativeLengths(e.Geometry)
+
+	rows, err := l.calibStmt.Query(elr)
+	if err != nil {
+		return ELR{}, fmt.Errorf("failed to load calibration of ELR %s: %w", elr, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c CalibrationSegment
+		var method, model, grade string
+		if err := rows.Scan(&c.TyFrom, &c.TyTo, &c.LoFrom, &c.LoTo, &c.Accuracy, &method, &model, &c.SlopeFrom, &c.SlopeTo, &grade); err != nil {
+			return ELR{}, fmt.Errorf("failed to load calibration of ELR %s: %w", elr, err)
+		}
+		if c.Method, err = ParseCalibrationMethod(method); err != nil {
+			return ELR{}, fmt.Errorf("failed to load calibration of ELR %s: %w", elr, err)
+		}
+		if c.Model, err = ParseCalibrationModel(model); err != nil {
+			return ELR{}, fmt.Errorf("failed to load calibration of ELR %s: %w", elr, err)
+		}
+		if c.Grade, err = ParseCalibrationGrade(grade); err != nil {
+			return ELR{}, fmt.Errorf("failed to load calibration of ELR %s: %w", elr, err)
+		}
+		e.CalibrationSegments = append(e.CalibrationSegments, c)
+	}
+
+	return e, rows.Err()
+}
+
+// bounds returns the bounding box of the geometry of every ELR with at least one segment, read from the
+// production database without the geometry being retained or becoming resident.
+func (l *lazyLoader) bounds() (map[string]orb.Bound, error) {
+	rows, err := l.db.Query("SELECT elr, geometry FROM elr")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bounds := make(map[string]orb.Bound, maxELRs)
+	for rows.Next() {
+		var elr string
+		var geometry orb.LineString
+		if err := rows.Scan(&elr, wkb.Scanner(&geometry)); err != nil {
+			return nil, err
+		}
+		if len(geometry) > 1 {
+			bounds[elr] = geometry.Bound()
+		}
+	}
+
+	return bounds, rows.Err()
+}
+
+// close closes the prepared statements, and the production database if opened by the loader.
+func (l *lazyLoader) close() error {
+	l.geomStmt.Close()
+	l.calibStmt.Close()
+	if !l.ownsDb {
+		return nil
+	}
+
+	return l.db.Close()
+}
+
+// elr returns the ELR with its geometry and calibration, loading it from the production database if lazy loading.
+// Returns ErrClosed once the Geocoder is closed.
+func (gc *Geocoder) elr(elr string) (ELR, error) {
+	if gc.closed.Load() {
+		return ELR{}, ErrClosed
+	}
+
+	e, ok := gc.ELRs[elr]
+	if !ok {
+		return ELR{}, fmt.Errorf("%w: %s", ErrUnknownELR, elr)
+	}
+
+	if gc.lazy == nil {
+		return e, nil
+	}
+
+	if resident, ok := gc.lazy.resident.get(elr); ok {
+		return resident, nil
+	}
+
+	e, err := gc.lazy.load(elr, e)
+	if err != nil {
+		return ELR{}, err
+	}
+
+	gc.lazy.resident.add(elr, e)
+	return e, nil
+}
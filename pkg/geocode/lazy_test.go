@@ -0,0 +1,187 @@
+package geocode
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkb"
+)
+
+// addTestELR adds an ELR running due north from the origin, proportionally calibrated at one yard per metre, to the production database.
+func addTestELR(t *testing.T, fn, elr string, x float64, tyTo int) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	geometry, err := wkb.Marshal(orb.LineString{{x, 0}, {x, float64(tyTo)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("INSERT INTO elr VALUES (?, 0, ?, ?, 'K', ?)", elr, tyTo, tyTo, geometry); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO calibration VALUES (?, 0, ?, 0, ?, 3, 'uncalibrated proportional', 'linear', 1, 1, 'E')", elr, tyTo, tyTo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLazyLoad(t *testing.T) {
+	dir := t.TempDir()
+	cfg := GeocoderConfig{
+		ProductionDbFn:  filepath.Join(dir, "production.db"),
+		CacheFn:         filepath.Join(dir, "unused.gob"),
+		LazyLoad:        true,
+		MaxResidentELRs: 2,
+	}
+
+	writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.0", 1_000)
+	addTestELR(t, cfg.ProductionDbFn, "DEF", 100, 500)
+	addTestELR(t, cfg.ProductionDbFn, "GHI", 200, 800)
+
+	gc, err := NewGeocoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gc.Close()
+
+	// Extents are available without loading geometry or calibration.
+	if len(gc.AllELRs()) != 3 || !gc.IsMetric("DEF") || gc.ELRs["GHI"].TyTo != 800 || gc.ELRs["GHI"].Geometry != nil {
+		t.Errorf("unexpected ELR extents: %v", gc.ELRs)
+	}
+	if gc.lazy.resident.len() != 0 {
+		t.Errorf("expected no resident ELRs, but got %d", gc.lazy.resident.len())
+	}
+
+	tests := []struct {
+		elr      string
+		ty       int
+		expected RailwayPoint
+	}{
+		{"ABC", 250, RailwayPoint{Point: orb.Point{250, 0}, Grade: GradeA}},
+		{"DEF", 100, RailwayPoint{Point: orb.Point{100, 100}, Accuracy: 3, Method: MethodProportional, Grade: GradeE}},
+		{"GHI", 800, RailwayPoint{Point: orb.Point{200, 800}, Accuracy: 3, Method: MethodProportional, Grade: GradeE}},
+		{"ABC", 1_000, RailwayPoint{Point: orb.Point{1_000, 0}, Grade: GradeA}},
+	}
+
+	for _, test := range tests {
+		pt, err := gc.Point(test.elr, test.ty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pt != test.expected {
+			t.Errorf("%s %d: expected %v, but got %v", test.elr, test.ty, test.expected, pt)
+		}
+		if gc.lazy.resident.len() > cfg.MaxResidentELRs {
+			t.Errorf("expected at most %d resident ELRs, but got %d", cfg.MaxResidentELRs, gc.lazy.resident.len())
+		}
+	}
+
+	if _, ok := gc.lazy.resident.get("DEF"); ok {
+		t.Errorf("expected least recently used DEF to be evicted")
+	}
+
+	offset, err := gc.PointOffset("DEF", 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (orb.Point{98, 100}); offset.Point != expected || offset.Accuracy != 3 {
+		t.Errorf("expected offset point %v, but got %v", expected, offset)
+	}
+
+	if _, err := gc.Point("XYZ", 0); !errors.Is(err, ErrUnknownELR) {
+		t.Errorf("expected ErrUnknownELR, but got %v", err)
+	}
+
+	locations, err := gc.Locate(orb.Point{105, 300}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0].ELR != "DEF" || locations[0].Ty != 300 {
+		t.Errorf("expected DEF 300, but got %v", locations)
+	}
+
+	if err := gc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gc.Point("GHI", 100); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Point after Close, but got %v", err)
+	}
+	if _, err := gc.Substring("GHI", 100, 200); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Substring after Close, but got %v", err)
+	}
+	if _, err := gc.Find("GHI", 100); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Find after Close, but got %v", err)
+	}
+	if _, err := gc.Bearing("GHI", 100); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Bearing after Close, but got %v", err)
+	}
+	if _, err := gc.Locate(orb.Point{105, 300}, 10); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Locate after Close, but got %v", err)
+	}
+}
+
+func TestLazySpatialIndex(t *testing.T) {
+	dir := t.TempDir()
+	cfg := GeocoderConfig{
+		ProductionDbFn:  filepath.Join(dir, "production.db"),
+		CacheFn:         filepath.Join(dir, "unused.gob"),
+		LazyLoad:        true,
+		MaxResidentELRs: 1,
+	}
+
+	writeTestProductionDb(t, cfg.ProductionDbFn, "1.0.0", 1_000)
+	addTestELR(t, cfg.ProductionDbFn, "DEF", 100, 500)
+	addTestELR(t, cfg.ProductionDbFn, "GHI", 200, 800)
+
+	gc, err := NewGeocoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gc.Close()
+
+	nearest, err := gc.NearestELRs(orb.Point{150, 300}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ELRDistance{{"DEF", 50}, {"GHI", 50}, {"ABC", 300}}
+	if !reflect.DeepEqual(nearest, expected) {
+		t.Errorf("expected %v, but got %v", expected, nearest)
+	}
+
+	// Only the bounding box of each ELR is indexed, and refinement loads geometry within the resident limit.
+	if len(gc.index.Load().items) != 3 {
+		t.Errorf("expected 3 bounding box items, but got %d", len(gc.index.Load().items))
+	}
+	if gc.lazy.resident.len() > cfg.MaxResidentELRs {
+		t.Errorf("expected at most %d resident ELRs, but got %d", cfg.MaxResidentELRs, gc.lazy.resident.len())
+	}
+
+	inBox, err := gc.ELRsInBBox(orb.Bound{Min: orb.Point{90, 400}, Max: orb.Point{110, 450}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(inBox, []string{"DEF"}) {
+		t.Errorf("expected [DEF], but got %v", inBox)
+	}
+
+	// A production database failure while building the index is returned to the caller.
+	failing, err := NewGeocoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing.lazy.db.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := failing.NearestELRs(orb.Point{150, 300}, 1); err == nil {
+			t.Errorf("expected error from spatial index on closed database")
+		}
+	}
+}
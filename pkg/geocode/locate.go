@@ -0,0 +1,90 @@
+// Reverse geocoding of geographic positions to railway ELR and mileage.
+
+package geocode
+
+import (
+	"fmt"
+
+	"github.com/paulmach/orb"
+)
+
+// Side represents the side of the ELR centre-line, looking in the direction of increasing mileage.
+type Side int
+
+const (
+	SideOn    Side = iota // On the centre-line.
+	SideLeft              // Left of the centre-line.
+	SideRight             // Right of the centre-line.
+)
+
+// String returns the textual representation of the side.
+func (s Side) String() string {
+	switch s {
+	case SideLeft:
+		return "left"
+	case SideRight:
+		return "right"
+	default:
+		return "on"
+	}
+}
+
+// Location represents a candidate railway position for a geographic point.
+type Location struct {
+	ELR      string    // ELR code.
+	Ty       int       // Mileage (as total yards).
+	Point    orb.Point // Nearest point on the ELR centre-line, Easting / Northing to EPSG:27700 (metres).
+	Distance float64   // Perpendicular distance from the geographic point to the centre-line (metres).
+	Side     Side      // Side of the centre-line the geographic point lies.
+	Accuracy float64   // Calibrated linear accuracy along railway (metres).
+}
+
+// Locate returns the candidate ELRs and mileages within the maximum distance (metres) of the
+// Easting / Northing point, ranked from nearest to furthest, with one candidate per ELR.
+func (gc *Geocoder) Locate(pt orb.Point, maxDist float64) ([]Location, error) {
+	if maxDist <= 0 {
+		return nil, fmt.Errorf("maximum distance must be positive: %f", maxDist)
+	}
+
+	// Candidates are already ranked by distance from the spatial index.
+	candidates, err := gc.ELRsWithin(pt, maxDist)
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]Location, 0, len(candidates))
+	for _, candidate := range candidates {
+		e, err := gc.elr(candidate.ELR)
+		if err != nil {
+			return nil, err
+		}
+
+		location, ok := locateOnELR(candidate.ELR, e, pt)
+		if ok {
+			locations = append(locations, location)
+		}
+	}
+
+	return locations, nil
+}
+
+// locateOnELR projects the point onto the ELR centre-line and inverts the calibration to establish the mileage.
+func locateOnELR(elr string, e ELR, pt orb.Point) (Location, bool) {
+	loc, ok := e.measuredLine().locate(pt)
+	if !ok {
+		return Location{}, false
+	}
+
+	calib, ok := findCalibrationSegmentByOffset(e.CalibrationSegments, loc.Measure)
+	if !ok {
+		return Location{}, false
+	}
+
+	return Location{
+		ELR:      elr,
+		Ty:       inverseInterpolateSegment(loc.Measure, calib),
+		Point:    loc.Point,
+		Distance: loc.Distance,
+		Side:     loc.Side,
+		Accuracy: calib.Accuracy,
+	}, true
+}
@@ -0,0 +1,88 @@
+package geocode
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestLocate(t *testing.T) {
+	gc := Geocoder{}
+	gc.ELRs = map[string]ELR{
+		"AAA": {
+			TyFrom:   0,
+			TyTo:     220,
+			ShapeLen: 200,
+			Geometry: orb.LineString{{0, 0}, {100, 0}, {200, 0}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 0, TyTo: 110, LoFrom: 0, LoTo: 100, Accuracy: -0.584},
+				{TyFrom: 110, TyTo: 220, LoFrom: 100, LoTo: 200, Accuracy: -0.584},
+			},
+		},
+		"BBB": {
+			TyFrom:   1_000,
+			TyTo:     1_100,
+			ShapeLen: 100,
+			Geometry: orb.LineString{{50, 100}, {50, 0}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 1_000, TyTo: 1_100, LoFrom: 0, LoTo: 100, Accuracy: 8.56},
+			},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		point    orb.Point
+		maxDist  float64
+		expected []Location
+	}{
+		{
+			name:    "Left of AAA only",
+			point:   orb.Point{150, 10},
+			maxDist: 20,
+			expected: []Location{
+				{ELR: "AAA", Ty: 165, Point: orb.Point{150, 0}, Distance: 10, Side: SideLeft, Accuracy: -0.584},
+			},
+		},
+		{
+			name:    "Right of AAA, nearer to BBB",
+			point:   orb.Point{55, -5},
+			maxDist: 20,
+			expected: []Location{
+				{ELR: "AAA", Ty: 61, Point: orb.Point{55, 0}, Distance: 5, Side: SideRight, Accuracy: -0.584},
+				{ELR: "BBB", Ty: 1_100, Point: orb.Point{50, 0}, Distance: 7.0710678118654755, Side: SideLeft, Accuracy: 8.56},
+			},
+		},
+		{
+			name:     "Beyond maximum distance",
+			point:    orb.Point{150, 50},
+			maxDist:  20,
+			expected: []Location{},
+		},
+	}
+
+	for _, c := range cases {
+		got, err := gc.Locate(c.point, c.maxDist)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+
+		if len(got) != len(c.expected) {
+			t.Errorf("%s: expected %d locations, but got %d: %v", c.name, len(c.expected), len(got), got)
+			continue
+		}
+
+		for i := range got {
+			g, e := got[i], c.expected[i]
+			if g.ELR != e.ELR || g.Ty != e.Ty || g.Side != e.Side || g.Accuracy != e.Accuracy ||
+				!almostEqual(g.Distance, e.Distance) || !almostEqual(g.Point.X(), e.Point.X()) || !almostEqual(g.Point.Y(), e.Point.Y()) {
+				t.Errorf("%s: expected %v, but got %v", c.name, c.expected[i], got[i])
+			}
+		}
+	}
+
+	if _, err := gc.Locate(orb.Point{0, 0}, 0); err == nil {
+		t.Error("expected error for non-positive maximum distance")
+	}
+}
@@ -0,0 +1,124 @@
+// Lookup of calibration segments, with options for mileages beyond the calibrated extent of an ELR.
+
+package geocode
+
+// LookupMode represents the treatment of mileages beyond the calibrated extent of an ELR.
+type LookupMode int
+
+const (
+	LookupStrict      LookupMode = iota // Mileages beyond the calibrated extent return an error.
+	LookupClamp                         // Mileages beyond the calibrated extent are clamped to the nearest end.
+	LookupExtrapolate                   // Mileages beyond the calibrated extent are extrapolated along the end tangent.
+)
+
+// Adjustment represents how a mileage beyond the calibrated extent of an ELR was resolved.
+type Adjustment int
+
+const (
+	AdjustNone         Adjustment = iota // Mileage is within the calibrated extent.
+	AdjustClamped                        // Mileage was clamped to the nearest end of the calibrated extent.
+	AdjustExtrapolated                   // Mileage was extrapolated beyond the calibrated extent.
+)
+
+// String returns the textual representation of the adjustment.
+func (a Adjustment) String() string {
+	switch a {
+	case AdjustClamped:
+		return "clamped"
+	case AdjustExtrapolated:
+		return "extrapolated"
+	default:
+		return "none"
+	}
+}
+
+// calibrationMatch represents the calibration segment resolved for a mileage on an ELR.
+type calibrationMatch struct {
+	elr        ELR                // ELR properties.
+	calib      CalibrationSegment // Calibration segment used for interpolation.
+	index      int                // Index of the calibration segment within the ELR calibration slice.
+	ty         int                // Mileage (as total yards) to interpolate, after any clamping.
+	adjustment Adjustment         // Adjustment made for a mileage beyond the calibrated extent.
+	overshoot  int                // Yards beyond the calibrated extent (zero if within).
+}
+
+// overshootMetres returns the distance (metres) the requested mileage lies beyond the calibrated extent.
+func (m calibrationMatch) overshootMetres() float64 {
+	return float64(m.overshoot) * YardsToMetres
+}
+
+// distance returns the linear offset (metres) along the ELR geometry for the resolved mileage.
+func (m calibrationMatch) distance() float64 {
+	return interpolateSegment(m.ty, m.calib)
+}
+
+// railwayPoint returns the point on the ELR linestring for the resolved mileage, with its linear accuracy.
+func (m calibrationMatch) railwayPoint() RailwayPoint {
+	return RailwayPoint{
+		Point:      m.elr.measuredLine().pointAtExtended(m.distance()),
+		Accuracy:   m.calib.Accuracy,
+		Adjustment: m.adjustment,
+		Overshoot:  m.overshootMetres(),
+		Method:     m.calib.Method,
+		Grade:      m.calib.Grade,
+	}
+}
+
+// match resolves the calibration segment for the mileage on the ELR, applying the configured lookup mode
+// to mileages beyond the calibrated extent.
+func (gc *Geocoder) match(elr string, ty int) (calibrationMatch, error) {
+	e, err := gc.elr(elr)
+	if err != nil {
+		return calibrationMatch{}, err
+	}
+
+	if i, ok := findCalibrationIndex(e.CalibrationSegments, ty); ok {
+		return calibrationMatch{elr: e, calib: e.CalibrationSegments[i], index: i, ty: ty}, nil
+	}
+
+	n := len(e.CalibrationSegments)
+	if gc.config.Lookup == LookupStrict || n == 0 {
+		return calibrationMatch{}, mileageOutOfRange(elr, ty, e)
+	}
+
+	// Mileage is before the start or beyond the end of the calibration, so use the respective end segment.
+	m := calibrationMatch{elr: e, index: 0, ty: ty}
+	first, last := e.CalibrationSegments[0], e.CalibrationSegments[n-1]
+	if ty < first.TyFrom {
+		m.calib, m.overshoot = first, first.TyFrom-ty
+		if gc.config.Lookup == LookupClamp {
+			m.ty = first.TyFrom
+		}
+	} else {
+		m.calib, m.index, m.overshoot = last, n-1, ty-last.TyTo
+		if gc.config.Lookup == LookupClamp {
+			m.ty = last.TyTo
+		}
+	}
+
+	m.adjustment = AdjustClamped
+	if gc.config.Lookup == LookupExtrapolate {
+		m.adjustment = AdjustExtrapolated
+	}
+
+	return m, nil
+}
+
+// findCalibrationIndex searches for the target yardage within the calibration slice, returning the segment index.
+func findCalibrationIndex(calibrationSegments []CalibrationSegment, tyTarget int) (int, bool) {
+	// Binary search; calibration slice is sorted by total yards from.
+	lo, hi := 0, len(calibrationSegments)-1
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+		if calibrationSegments[mid].TyFrom <= tyTarget && tyTarget <= calibrationSegments[mid].TyTo {
+			return mid, true
+		} else if tyTarget < calibrationSegments[mid].TyFrom {
+			hi = mid - 1
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	// Target yardage not found in calibration slice.
+	return 0, false
+}
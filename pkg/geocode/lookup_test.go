@@ -0,0 +1,105 @@
+package geocode
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkt"
+)
+
+// lookupGeocoder returns a geocoder with a single ELR calibrated from 100 to 300 total yards, over 200 metres.
+func lookupGeocoder(mode LookupMode) *Geocoder {
+	gc := &Geocoder{config: GeocoderConfig{Lookup: mode}}
+	gc.ELRs = map[string]ELR{
+		"ABC": {
+			TyFrom:   100,
+			TyTo:     300,
+			ShapeLen: 200,
+			Geometry: orb.LineString{{0, 0}, {100, 0}, {100, 100}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 100, TyTo: 200, LoFrom: 0, LoTo: 100, Accuracy: 1},
+				{TyFrom: 200, TyTo: 300, LoFrom: 100, LoTo: 200, Accuracy: 2},
+			},
+		},
+	}
+
+	return gc
+}
+
+func TestPointLookupModes(t *testing.T) {
+	cases := []struct {
+		name       string
+		mode       LookupMode
+		ty         int
+		expectErr  bool
+		point      orb.Point
+		accuracy   float64
+		adjustment Adjustment
+		overshoot  float64
+	}{
+		{name: "Strict within", mode: LookupStrict, ty: 150, point: orb.Point{50, 0}, accuracy: 1, adjustment: AdjustNone},
+		{name: "Strict before", mode: LookupStrict, ty: 90, expectErr: true},
+		{name: "Strict beyond", mode: LookupStrict, ty: 310, expectErr: true},
+		{name: "Clamp within", mode: LookupClamp, ty: 250, point: orb.Point{100, 50}, accuracy: 2, adjustment: AdjustNone},
+		{name: "Clamp before", mode: LookupClamp, ty: 90, point: orb.Point{0, 0}, accuracy: 1, adjustment: AdjustClamped, overshoot: 9.144},
+		{name: "Clamp beyond", mode: LookupClamp, ty: 310, point: orb.Point{100, 100}, accuracy: 2, adjustment: AdjustClamped, overshoot: 9.144},
+		{name: "Extrapolate before", mode: LookupExtrapolate, ty: 90, point: orb.Point{-10, 0}, accuracy: 1, adjustment: AdjustExtrapolated, overshoot: 9.144},
+		{name: "Extrapolate beyond", mode: LookupExtrapolate, ty: 320, point: orb.Point{100, 120}, accuracy: 2, adjustment: AdjustExtrapolated, overshoot: 18.288},
+	}
+
+	const Epsilon = 1e-9
+
+	for _, c := range cases {
+		gc := lookupGeocoder(c.mode)
+		rp, err := gc.Point("ABC", c.ty)
+		if c.expectErr {
+			var outOfRange ErrMileageOutOfRange
+			if !errors.As(err, &outOfRange) {
+				t.Errorf("%s: expected ErrMileageOutOfRange, but got %v", c.name, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+
+		if math.Abs(rp.Point.X()-c.point.X()) > Epsilon || math.Abs(rp.Point.Y()-c.point.Y()) > Epsilon ||
+			rp.Accuracy != c.accuracy || rp.Adjustment != c.adjustment || math.Abs(rp.Overshoot-c.overshoot) > Epsilon {
+			t.Errorf("%s: expected {%v %v %v %v}, but got %v", c.name, c.point, c.accuracy, c.adjustment, c.overshoot, rp)
+		}
+	}
+}
+
+func TestSubstringLookupModes(t *testing.T) {
+	cases := []struct {
+		name        string
+		mode        LookupMode
+		tyFrom      int
+		tyTo        int
+		expectedWKT string
+	}{
+		{name: "Clamp both ends", mode: LookupClamp, tyFrom: 50, tyTo: 400, expectedWKT: "LINESTRING(0 0,100 0,100 100)"},
+		{name: "Extrapolate both ends", mode: LookupExtrapolate, tyFrom: 95, tyTo: 305, expectedWKT: "LINESTRING(-5 0,0 0,100 0,100 105)"},
+	}
+
+	for _, c := range cases {
+		gc := lookupGeocoder(c.mode)
+		res, err := gc.Substring("ABC", c.tyFrom, c.tyTo)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+
+		if got := wkt.MarshalString(res.Geometry); got != c.expectedWKT {
+			t.Errorf("%s: expected %s, but got %s", c.name, c.expectedWKT, got)
+		}
+	}
+
+	if _, err := lookupGeocoder(LookupStrict).Substring("ABC", 50, 200); err == nil {
+		t.Error("expected error for strict lookup beyond calibrated extent")
+	}
+}
@@ -0,0 +1,202 @@
+// Mileage value type, with parsing and formatting of the common railway mileage and kilometreage notations.
+
+package geocode
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	YardsInChain  int = 22 // Number of yards in a chain.
+	ChainsInMile      = 80 // Number of chains in a mile.
+	yardsDigits       = 4  // Number of digits for yards in dotted miles / yards notation.
+	metresInKm        = 1_000.0
+	decimalPlaces     = 3 // Decimal places for decimal miles and kilometres.
+)
+
+// Mileage represents a linear position along an ELR as signed total yards, with its reporting unit system.
+type Mileage struct {
+	TotalYards int  // Total yards (irrespective of reporting unit system).
+	Metric     bool // Linear referencing reporting unit system is kilometres.
+}
+
+var (
+	// Miles and yards, e.g. "86M 0007y", "86 miles 7 yards", "86m 7yds".
+	regexMilesYards = regexp.MustCompile(`^(\d+)\s*m(?:iles?)?\s*(\d+)\s*y(?:ards?|ds)?$`)
+	// Miles and chains, e.g. "86m 07ch", "86 miles 7 chains".
+	regexMilesChains = regexp.MustCompile(`^(\d+)\s*m(?:iles?)?\s*(\d+)\s*ch(?:ains?)?$`)
+	// Whole miles, e.g. "86M", "86 miles".
+	regexMiles = regexp.MustCompile(`^(\d+)\s*m(?:iles?)?$`)
+	// Decimal miles, e.g. "86.004 miles".
+	regexDecimalMiles = regexp.MustCompile(`^(\d+\.\d+)\s*(?:m|mi|miles?)$`)
+	// Dotted miles and (four digit) yards, e.g. "86.0007".
+	regexDottedMilesYards = regexp.MustCompile(`^(\d+)\.(\d{4})$`)
+	// Kilometres, e.g. "12.345km", "12.345 km".
+	regexKm = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*km$`)
+)
+
+// NewMileage returns a Mileage for the total yards and reporting unit system.
+func NewMileage(totalYards int, metric bool) Mileage {
+	return Mileage{TotalYards: totalYards, Metric: metric}
+}
+
+// ParseMileage parses a mileage or kilometreage string, optionally negative, in any of the notations
+// "86M 0007y", "86 miles 7 yards", "86m 07ch", "86.0007" (dotted miles / yards), "86.004 miles" or "12.345km".
+func ParseMileage(s string) (Mileage, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	sign := 1
+	if rest, ok := strings.CutPrefix(text, "-"); ok {
+		sign, text = -1, strings.TrimSpace(rest)
+	}
+
+	if m := regexKm.FindStringSubmatch(text); m != nil {
+		km, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return Mileage{}, fmt.Errorf("invalid kilometreage %q: %w", s, err)
+		}
+		return Mileage{TotalYards: sign * int(math.Round(km*metresInKm/YardsToMetres)), Metric: true}, nil
+	}
+
+	if m := regexDecimalMiles.FindStringSubmatch(text); m != nil {
+		miles, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return Mileage{}, fmt.Errorf("invalid decimal miles %q: %w", s, err)
+		}
+		return Mileage{TotalYards: sign * int(math.Round(miles*float64(YardsInMile)))}, nil
+	}
+
+	var (
+		miles, part int
+		unit        = 1
+		m           []string
+	)
+
+	switch {
+	case regexMilesYards.MatchString(text):
+		m = regexMilesYards.FindStringSubmatch(text)
+	case regexDottedMilesYards.MatchString(text):
+		m = regexDottedMilesYards.FindStringSubmatch(text)
+	case regexMilesChains.MatchString(text):
+		m, unit = regexMilesChains.FindStringSubmatch(text), YardsInChain
+	case regexMiles.MatchString(text):
+		m = append(regexMiles.FindStringSubmatch(text), "0")
+	default:
+		return Mileage{}, fmt.Errorf("unrecognised mileage notation: %q", s)
+	}
+
+	miles, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Mileage{}, fmt.Errorf("invalid miles %q: %w", s, err)
+	}
+
+	part, err = strconv.Atoi(m[2])
+	if err != nil {
+		return Mileage{}, fmt.Errorf("invalid yards or chains %q: %w", s, err)
+	}
+
+	if part*unit >= YardsInMile {
+		return Mileage{}, fmt.Errorf("yards or chains exceed one mile: %q", s)
+	}
+
+	return Mileage{TotalYards: sign * BuildTotalYards(miles, part*unit)}, nil
+}
+
+// split returns the sign prefix, and the absolute miles and yards components of the mileage.
+func (m Mileage) split() (string, int, int) {
+	sign, ty := "", m.TotalYards
+	if ty < 0 {
+		sign, ty = "-", -ty
+	}
+
+	return sign, ty / YardsInMile, ty % YardsInMile
+}
+
+// String returns the mileage in miles / yards notation (e.g. "86M 0007y"), or kilometres if metric (e.g. "12.345km").
+// Unlike FmtTotalYards, negative mileages of any magnitude are formatted with a leading sign (e.g. "-1M 0200y").
+func (m Mileage) String() string {
+	if m.Metric {
+		return m.Km()
+	}
+
+	return m.MilesYards()
+}
+
+// MilesYards returns the mileage in miles / yards notation, e.g. "86M 0007y".
+func (m Mileage) MilesYards() string {
+	sign, miles, yards := m.split()
+	return fmt.Sprintf("%s%dM %04dy", sign, miles, yards)
+}
+
+// MilesChains returns the mileage in miles / chains notation, rounded to the nearest chain, e.g. "86m 07ch".
+func (m Mileage) MilesChains() string {
+	sign, miles, yards := m.split()
+	chains := int(math.Round(float64(yards) / float64(YardsInChain)))
+	if chains == ChainsInMile {
+		miles, chains = miles+1, 0
+	}
+
+	return fmt.Sprintf("%s%dm %02dch", sign, miles, chains)
+}
+
+// Dotted returns the mileage in dotted miles / yards notation, e.g. "86.0007".
+func (m Mileage) Dotted() string {
+	sign, miles, yards := m.split()
+	return fmt.Sprintf("%s%d.%0*d", sign, miles, yardsDigits, yards)
+}
+
+// DecimalMiles returns the mileage in decimal miles, e.g. "86.004 miles".
+func (m Mileage) DecimalMiles() string {
+	return fmt.Sprintf("%.*f miles", decimalPlaces, float64(m.TotalYards)/float64(YardsInMile))
+}
+
+// Km returns the mileage in kilometres, e.g. "12.345km".
+func (m Mileage) Km() string {
+	return fmt.Sprintf("%.*fkm", decimalPlaces, m.Metres()/metresInKm)
+}
+
+// Metres returns the mileage as a distance in metres.
+func (m Mileage) Metres() float64 {
+	return float64(m.TotalYards) * YardsToMetres
+}
+
+// Add returns the mileage advanced by the number of yards (negative to reduce).
+func (m Mileage) Add(yards int) Mileage {
+	return Mileage{TotalYards: m.TotalYards + yards, Metric: m.Metric}
+}
+
+// Sub returns the difference in yards between the mileage and another.
+func (m Mileage) Sub(other Mileage) int {
+	return m.TotalYards - other.TotalYards
+}
+
+// Compare returns -1, 0 or +1 depending on whether the mileage is less than, equal to, or greater than another.
+func (m Mileage) Compare(other Mileage) int {
+	switch {
+	case m.TotalYards < other.TotalYards:
+		return -1
+	case m.TotalYards > other.TotalYards:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler (and so JSON marshalling), using the String notation.
+func (m Mileage) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler (and so JSON unmarshalling), accepting any ParseMileage notation.
+func (m *Mileage) UnmarshalText(text []byte) error {
+	parsed, err := ParseMileage(string(text))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,190 @@
+package geocode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMileage(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected Mileage
+	}{
+		{text: "86M 0007y", expected: Mileage{TotalYards: 151_367}},
+		{text: "86m 7y", expected: Mileage{TotalYards: 151_367}},
+		{text: "86 miles 7 yards", expected: Mileage{TotalYards: 151_367}},
+		{text: "86 Miles 7 Yds", expected: Mileage{TotalYards: 151_367}},
+		{text: " 86.0007 ", expected: Mileage{TotalYards: 151_367}},
+		{text: "86m 07ch", expected: Mileage{TotalYards: 151_514}},
+		{text: "86 miles 7 chains", expected: Mileage{TotalYards: 151_514}},
+		{text: "86 miles", expected: Mileage{TotalYards: 151_360}},
+		{text: "86.5 miles", expected: Mileage{TotalYards: 152_240}},
+		{text: "0M 0000y", expected: Mileage{TotalYards: 0}},
+		{text: "-0M 0216y", expected: Mileage{TotalYards: -216}},
+		{text: "-1M 0200y", expected: Mileage{TotalYards: -1_960}},
+		{text: "-1.0200", expected: Mileage{TotalYards: -1_960}},
+		{text: "12.345km", expected: Mileage{TotalYards: 13_501, Metric: true}},
+		{text: "12.345 KM", expected: Mileage{TotalYards: 13_501, Metric: true}},
+		{text: "-0.100km", expected: Mileage{TotalYards: -109, Metric: true}},
+	}
+
+	for _, c := range cases {
+		got, err := ParseMileage(c.text)
+		if err != nil {
+			t.Errorf("ParseMileage(%q) returned error: %v", c.text, err)
+		} else if got != c.expected {
+			t.Errorf("ParseMileage(%q) = %v; want %v", c.text, got, c.expected)
+		}
+	}
+}
+
+func TestBadParseMileage(t *testing.T) {
+	invalid := []string{
+		"",
+		"-",
+		"abc",
+		"86",
+		"86.007",
+		"86M 1760y",
+		"86m 80ch",
+		"86M -7y",
+		"12.345",
+		"km",
+	}
+
+	for _, text := range invalid {
+		if got, err := ParseMileage(text); err == nil {
+			t.Errorf("ParseMileage(%q) = %v; want error", text, got)
+		}
+	}
+}
+
+func TestMileageFormats(t *testing.T) {
+	cases := []struct {
+		mileage      Mileage
+		milesYards   string
+		milesChains  string
+		dotted       string
+		decimalMiles string
+		km           string
+	}{
+		{
+			mileage:      Mileage{TotalYards: 151_367},
+			milesYards:   "86M 0007y",
+			milesChains:  "86m 00ch",
+			dotted:       "86.0007",
+			decimalMiles: "86.004 miles",
+			km:           "138.410km",
+		},
+		{
+			mileage:      Mileage{TotalYards: 3_519},
+			milesYards:   "1M 1759y",
+			milesChains:  "2m 00ch",
+			dotted:       "1.1759",
+			decimalMiles: "1.999 miles",
+			km:           "3.218km",
+		},
+		{
+			mileage:      Mileage{TotalYards: -1_960},
+			milesYards:   "-1M 0200y",
+			milesChains:  "-1m 09ch",
+			dotted:       "-1.0200",
+			decimalMiles: "-1.114 miles",
+			km:           "-1.792km",
+		},
+	}
+
+	for _, c := range cases {
+		if got := c.mileage.MilesYards(); got != c.milesYards {
+			t.Errorf("MilesYards(%d) = %s; want %s", c.mileage.TotalYards, got, c.milesYards)
+		}
+		if got := c.mileage.MilesChains(); got != c.milesChains {
+			t.Errorf("MilesChains(%d) = %s; want %s", c.mileage.TotalYards, got, c.milesChains)
+		}
+		if got := c.mileage.Dotted(); got != c.dotted {
+			t.Errorf("Dotted(%d) = %s; want %s", c.mileage.TotalYards, got, c.dotted)
+		}
+		if got := c.mileage.DecimalMiles(); got != c.decimalMiles {
+			t.Errorf("DecimalMiles(%d) = %s; want %s", c.mileage.TotalYards, got, c.decimalMiles)
+		}
+		if got := c.mileage.Km(); got != c.km {
+			t.Errorf("Km(%d) = %s; want %s", c.mileage.TotalYards, got, c.km)
+		}
+	}
+
+	// String agrees with FmtTotalYards for non-negative mileages.
+	for _, ty := range []int{0, 1, 1_759, 1_760, 151_367} {
+		for _, metric := range []bool{false, true} {
+			if got, want := NewMileage(ty, metric).String(), FmtTotalYards(ty, metric); got != want {
+				t.Errorf("String(%d, %t) = %s; want %s", ty, metric, got, want)
+			}
+		}
+	}
+}
+
+func TestMileageRoundTrip(t *testing.T) {
+	for ty := -3_600; ty <= 3_600; ty += 7 {
+		for _, m := range []Mileage{NewMileage(ty, false), NewMileage(ty, true)} {
+			// Kilometres to three decimal places are coarser than a yard, so compare the formatted text.
+			parsed, err := ParseMileage(m.String())
+			if err != nil || parsed.String() != m.String() || parsed.Metric != m.Metric {
+				t.Errorf("ParseMileage(%q) = %v, %v; want %v", m.String(), parsed, err, m)
+			}
+
+			parsed, err = ParseMileage(m.Dotted())
+			if err != nil || parsed.TotalYards != m.TotalYards {
+				t.Errorf("ParseMileage(%q) = %v, %v; want %d total yards", m.Dotted(), parsed, err, m.TotalYards)
+			}
+		}
+	}
+}
+
+func TestMileageArithmetic(t *testing.T) {
+	a := NewMileage(1_000, false)
+	b := a.Add(760)
+
+	if b.TotalYards != 1_760 || b.String() != "1M 0000y" {
+		t.Errorf("Add = %v; want 1M 0000y", b)
+	}
+
+	if d := b.Sub(a); d != 760 {
+		t.Errorf("Sub = %d; want 760", d)
+	}
+
+	if a.Compare(b) != -1 || b.Compare(a) != 1 || a.Compare(a) != 0 {
+		t.Errorf("Compare ordering incorrect for %v and %v", a, b)
+	}
+
+	if !almostEqual(b.Metres(), 1_609.344) {
+		t.Errorf("Metres = %v; want 1609.344", b.Metres())
+	}
+}
+
+func TestMileageJSON(t *testing.T) {
+	type asset struct {
+		ELR     string
+		Mileage Mileage
+	}
+
+	data, err := json.Marshal(asset{"ECM1", NewMileage(-1_960, false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"ELR":"ECM1","Mileage":"-1M 0200y"}`; string(data) != expected {
+		t.Errorf("json.Marshal = %s; want %s", data, expected)
+	}
+
+	var got asset
+	if err := json.Unmarshal([]byte(`{"ELR":"CTR","Mileage":"12.345km"}`), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Mileage != NewMileage(13_501, true) {
+		t.Errorf("json.Unmarshal = %v; want 13501 metric total yards", got.Mileage)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Mileage":"nonsense"}`), &got); err == nil {
+		t.Error("expected error unmarshalling invalid mileage")
+	}
+}
@@ -0,0 +1,23 @@
+// Reading of the binary cache into memory, on platforms without mmap support.
+
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package geocode
+
+import "os"
+
+// mapFile reads the file into 8-byte aligned memory, returning its contents and a no-op function in place of unmapping.
+func mapFile(fn string) ([]byte, func() error, error) {
+	file, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	data, err := readAligned(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
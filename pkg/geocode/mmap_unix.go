@@ -0,0 +1,35 @@
+// Memory mapping of the binary cache, on platforms supporting mmap.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package geocode
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file read-only into memory, returning its contents and a function to unmap it.
+func mapFile(fn string) ([]byte, func() error, error) {
+	file, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close() // Mapping remains valid once the file is closed.
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.Size() == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
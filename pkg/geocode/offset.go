@@ -0,0 +1,50 @@
+// Lateral offset of railway positions perpendicular to the ELR centre-line.
+
+package geocode
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/paulmach/orb"
+)
+
+// PointOffset returns the point for a given distance (as total yards) on the ELR linestring, offset perpendicular
+// to the centre-line by the lateral distance (metres); positive to the left and negative to the right, looking in
+// the direction of increasing mileage.
+func (gc *Geocoder) PointOffset(elr string, ty int, lateralMetres float64) (RailwayPoint, error) {
+	m, err := gc.match(elr, ty)
+	if err != nil {
+		if gc.config.VerboseOutput {
+			log.Printf(calibrationNotFound, elr, ty)
+		}
+		return RailwayPoint{}, err
+	}
+
+	rp := m.railwayPoint()
+	if lateralMetres == 0 {
+		return rp, nil
+	}
+
+	i, ok := m.elr.measuredLine().segmentAt(m.distance())
+	if !ok {
+		return RailwayPoint{}, fmt.Errorf("ELR %s has no geometry to establish offset", elr)
+	}
+
+	normal := leftNormal(m.elr.Geometry[i], m.elr.Geometry[i+1])
+	rp.Point = orb.Point{rp.Point.X() + normal.X()*lateralMetres, rp.Point.Y() + normal.Y()*lateralMetres}
+	return rp, nil
+}
+
+// SubstringOffset returns a portion of the ELR linestring based on the start and end distances (as total yards),
+// offset parallel to the centre-line by the lateral distance (metres); positive to the left and negative to the
+// right, looking in the direction of increasing mileage. Measured length refers to the centre-line.
+func (gc *Geocoder) SubstringOffset(elr string, tyFrom, tyTo int, lateralMetres float64) (SubstringResult, error) {
+	res, err := gc.Substring(elr, tyFrom, tyTo)
+	if err != nil || lateralMetres == 0 {
+		return res, err
+	}
+
+	res.Geometry = offsetLine(res.Geometry, lateralMetres)
+	return res, nil
+}
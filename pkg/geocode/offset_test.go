@@ -0,0 +1,133 @@
+package geocode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// offsetGeocoder returns a geocoder with a single ELR turning right through 90 degrees, 1 yard per metre.
+func offsetGeocoder() *Geocoder {
+	gc := &Geocoder{}
+	gc.ELRs = map[string]ELR{
+		"ABC": {
+			TyFrom:   0,
+			TyTo:     200,
+			Geometry: orb.LineString{{0, 0}, {0, 100}, {100, 100}},
+			CalibrationSegments: []CalibrationSegment{
+				{TyFrom: 0, TyTo: 200, LoFrom: 0, LoTo: 200, Accuracy: 3},
+			},
+		},
+	}
+
+	return gc
+}
+
+func TestPointOffset(t *testing.T) {
+	gc := offsetGeocoder()
+
+	cases := []struct {
+		ty       int
+		lateral  float64
+		expected orb.Point
+	}{
+		{ty: 50, lateral: 3.5, expected: orb.Point{-3.5, 50}},
+		{ty: 50, lateral: -3.5, expected: orb.Point{3.5, 50}},
+		{ty: 150, lateral: 2, expected: orb.Point{50, 102}},
+		{ty: 150, lateral: 0, expected: orb.Point{50, 100}},
+	}
+
+	const Epsilon = 1e-9
+
+	for _, c := range cases {
+		rp, err := gc.PointOffset("ABC", c.ty, c.lateral)
+		if err != nil {
+			t.Errorf("PointOffset(%d, %v) returned error: %v", c.ty, c.lateral, err)
+			continue
+		}
+
+		if math.Abs(rp.Point.X()-c.expected.X()) > Epsilon || math.Abs(rp.Point.Y()-c.expected.Y()) > Epsilon || rp.Accuracy != 3 {
+			t.Errorf("PointOffset(%d, %v) = %v; want %v", c.ty, c.lateral, rp, c.expected)
+		}
+	}
+}
+
+func TestSubstringOffset(t *testing.T) {
+	gc := offsetGeocoder()
+
+	res, err := gc.SubstringOffset("ABC", 50, 150, -2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Offset to the right, on the inside of the right-hand curve, mitred at the vertex.
+	expected := orb.LineString{{2, 50}, {2, 98}, {50, 98}}
+	if len(res.Geometry) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, res.Geometry)
+	}
+
+	for i := range expected {
+		if math.Abs(res.Geometry[i].X()-expected[i].X()) > 1e-9 || math.Abs(res.Geometry[i].Y()-expected[i].Y()) > 1e-9 {
+			t.Errorf("expected %v, but got %v", expected, res.Geometry)
+			break
+		}
+	}
+}
+
+func TestOffsetLine(t *testing.T) {
+	cases := []struct {
+		name     string
+		line     orb.LineString
+		offset   float64
+		expected orb.LineString
+	}{
+		{
+			name:     "Straight left",
+			line:     orb.LineString{{0, 0}, {10, 0}, {20, 0}},
+			offset:   1,
+			expected: orb.LineString{{0, 1}, {10, 1}, {20, 1}},
+		},
+		{
+			name:     "Right angle, outside of turn",
+			line:     orb.LineString{{0, 0}, {10, 0}, {10, 10}},
+			offset:   -1,
+			expected: orb.LineString{{0, -1}, {11, -1}, {11, 10}},
+		},
+		{
+			name:     "Repeated point",
+			line:     orb.LineString{{0, 0}, {10, 0}, {10, 0}, {10, 10}},
+			offset:   1,
+			expected: orb.LineString{{0, 1}, {9, 1}, {9, 10}},
+		},
+		{
+			name:     "Reversal is bevelled",
+			line:     orb.LineString{{0, 0}, {10, 0}, {0, 0.1}},
+			offset:   1,
+			expected: orb.LineString{{0, 1}, {10, 1}, {9.9900005, -0.99995}, {-0.0099995, -0.89995}},
+		},
+		{
+			name:     "Single point",
+			line:     orb.LineString{{5, 5}, {5, 5}},
+			offset:   1,
+			expected: orb.LineString{{5, 5}},
+		},
+	}
+
+	const Epsilon = 1e-6
+
+	for _, c := range cases {
+		got := offsetLine(c.line, c.offset)
+		if len(got) != len(c.expected) {
+			t.Errorf("%s: expected %v, but got %v", c.name, c.expected, got)
+			continue
+		}
+
+		for i := range got {
+			if math.Abs(got[i].X()-c.expected[i].X()) > Epsilon || math.Abs(got[i].Y()-c.expected[i].Y()) > Epsilon {
+				t.Errorf("%s: expected %v, but got %v", c.name, c.expected, got)
+				break
+			}
+		}
+	}
+}
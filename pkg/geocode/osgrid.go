@@ -63,7 +63,16 @@ func PointToOSGR(pt orb.Point) string {
 
 // OSGRToPoint returns the Easting / Northing point for the given OSGR string.
 func OSGRToPoint(osgr string) (orb.Point, error) {
+	if len(osgr) < 2 || len(osgr)%2 != 0 {
+		return orb.Point{0, 0}, fmt.Errorf("invalid OSGR length: %q", osgr)
+	}
+
 	prefix := osgr[:2]
+	ixLetter1 := strings.Index(TileLetterOrder, string(prefix[0]))
+	ixLetter2 := strings.Index(TileLetterOrder, string(prefix[1]))
+	if ixLetter1 < 0 || ixLetter2 < 0 {
+		return orb.Point{0, 0}, fmt.Errorf("invalid OSGR tile letters: %q", osgr)
+	}
 
 	numbers := osgr[2:]
 	numbersMidPoint := len(numbers) / 2
@@ -77,11 +86,9 @@ func OSGRToPoint(osgr string) (orb.Point, error) {
 		return orb.Point{0, 0}, err
 	}
 
-	ixLetter1 := strings.Index(TileLetterOrder, string(prefix[0]))
 	originX := PrimaryTileSize*(ixLetter1%TilesPerRow) + GridOriginSWEasting
 	originY := PrimaryTileSize*(ixLetter1/TilesPerRow) + GridOriginSWNorthing
 
-	ixLetter2 := strings.Index(TileLetterOrder, string(prefix[1]))
 	originX += SecondaryTileSize * (ixLetter2 % TilesPerRow)
 	originY += SecondaryTileSize * (ixLetter2 / TilesPerRow)
 
@@ -36,6 +36,10 @@ func TestBadOSGRToPoint(t *testing.T) {
 	invalidOSGRs := []string{
 		"ZZabcdef",
 		"AA1234abcd",
+		"",
+		"T",
+		"TQ123",
+		"IQ1234",
 	}
 
 	for _, osgr := range invalidOSGRs {
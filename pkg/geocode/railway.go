@@ -14,10 +14,11 @@ const (
 	YardsToMetres    float64 = 0.9144    // Yards to metres conversion factor.
 )
 
+// elrRegex is the compiled regular expression for validating ELR codes.
+var elrRegex = regexp.MustCompile(`[A-Z]{3}\d?$`)
+
 // RegexELR returns a compiled regular expression for validating ELR codes.
 func RegexELR() *regexp.Regexp {
-	elrRegex, err := regexp.Compile(`[A-Z]{3}\d?$`)
-	Check(err)
 	return elrRegex
 }
 
@@ -3,6 +3,8 @@
 package geocode
 
 import (
+	"fmt"
+
 	"github.com/paulmach/orb"
 	"github.com/twpayne/go-proj/v10"
 )
@@ -16,33 +18,39 @@ const (
 )
 
 // OSGBToLonLat returns a pointer to the transformer from projected OSGB36 (EPSG:27700) to geographic Longitude / Latitude (EPSG:4326).
-func OSGBToLonLat() *proj.PJ {
+func OSGBToLonLat() (*proj.PJ, error) {
 	pj, err := proj.NewCRSToCRS(ProjectedCRS, GeographicCRS, nil)
-	Check(err)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s to %s: %v", ErrProjection, ProjectedCRS, GeographicCRS, err)
+	}
 
-	return pj
+	return pj, nil
 }
 
 // Reproject takes a projected Easting / Northing point and returns the corresponding Longitude / Latitude point.
-func Reproject(point orb.Point, pj *proj.PJ) orb.Point {
+func Reproject(point orb.Point, pj *proj.PJ) (orb.Point, error) {
 	latLon, err := pj.Forward(proj.Coord{point.X(), point.Y()})
-	Check(err)
+	if err != nil {
+		return orb.Point{}, fmt.Errorf("%w: %v: %v", ErrProjection, point, err)
+	}
 
 	// Note order of X / Y versus Longitude / Latitude is intentional (due to library utilising GDAL).
-	return orb.Point{latLon.Y(), latLon.X()}
+	return orb.Point{latLon.Y(), latLon.X()}, nil
 }
 
 // ReprojectMulti takes a slice of projected Easting / Northing points and returns the corresponding Longitude / Latitude points slice.
-func ReprojectMulti(points []orb.Point, pj *proj.PJ) []orb.Point {
+func ReprojectMulti(points []orb.Point, pj *proj.PJ) ([]orb.Point, error) {
 	latLons := make([]orb.Point, len(points))
 
 	for i, point := range points {
 		latLon, err := pj.Forward(proj.Coord{point.X(), point.Y()})
-		Check(err)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrProjection, point, err)
+		}
 
 		// Note order of X / Y versus Longitude / Latitude is intentional (due to library utilising GDAL).
 		latLons[i] = orb.Point{latLon.Y(), latLon.X()}
 	}
 
-	return latLons
+	return latLons, nil
 }
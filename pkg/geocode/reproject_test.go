@@ -17,7 +17,15 @@ func TestReproject(t *testing.T) {
 		planarPoints = append(planarPoints, orb.Point{float64(testPlace.easting), float64(testPlace.northing)})
 	}
 
-	geoPoints := ReprojectMulti(planarPoints, OSGBToLonLat())
+	pjToGeo, err := OSGBToLonLat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	geoPoints, err := ReprojectMulti(planarPoints, pjToGeo)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i, testPlace := range testPlaces {
 		deltaX := geoPoints[i].Point().X() - testPlace.lonLat.X()
 		deltaY := geoPoints[i].Point().Y() - testPlace.lonLat.Y()
@@ -28,9 +36,11 @@ func TestReproject(t *testing.T) {
 		}
 	}
 
-	pjToGeo := OSGBToLonLat()
 	for _, testPlace := range testPlaces {
-		geoPoint := Reproject(orb.Point{float64(testPlace.easting), float64(testPlace.northing)}, pjToGeo)
+		geoPoint, err := Reproject(orb.Point{float64(testPlace.easting), float64(testPlace.northing)}, pjToGeo)
+		if err != nil {
+			t.Fatal(err)
+		}
 		deltaX := geoPoint.X() - testPlace.lonLat.X()
 		deltaY := geoPoint.Y() - testPlace.lonLat.Y()
 
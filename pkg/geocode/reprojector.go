@@ -0,0 +1,122 @@
+// Concurrency-safe reprojection between OSGB36 Easting / Northing and a target Co-ordinate Reference System.
+
+package geocode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/paulmach/orb"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// Reprojector transforms points between projected OSGB36 (EPSG:27700) and a target CRS, and is safe for concurrent use.
+// PROJ contexts and transformers cannot be shared between goroutines, so each transformation borrows a transformer
+// (with its own context) from a pool, creating one as required.
+type Reprojector struct {
+	targetCRS string    // Target Co-ordinate Reference System, e.g. "EPSG:4326".
+	pool      sync.Pool // Idle transformers (*proj.PJ), each owning a separate PROJ context.
+}
+
+// NewReprojector returns a Reprojector between projected OSGB36 (EPSG:27700) and the target CRS.
+// Geographic target co-ordinates are ordered Longitude / Latitude, irrespective of the CRS axis order.
+func NewReprojector(targetCRS string) (*Reprojector, error) {
+	r := &Reprojector{targetCRS: targetCRS}
+
+	// Create the first transformer up front, so an invalid target CRS is reported here rather than on use.
+	pj, err := r.newTransformer()
+	if err != nil {
+		return nil, err
+	}
+	r.pool.Put(pj)
+
+	return r, nil
+}
+
+// TargetCRS returns the target Co-ordinate Reference System.
+func (r *Reprojector) TargetCRS() string {
+	return r.targetCRS
+}
+
+// newTransformer returns a transformer from OSGB36 to the target CRS, within a new PROJ context.
+func (r *Reprojector) newTransformer() (*proj.PJ, error) {
+	pj, err := proj.NewContext().NewCRSToCRS(ProjectedCRS, r.targetCRS, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s to %s: %v", ErrProjection, ProjectedCRS, r.targetCRS, err)
+	}
+	defer pj.Destroy()
+
+	normalised, err := pj.NormalizeForVisualization()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s to %s: %v", ErrProjection, ProjectedCRS, r.targetCRS, err)
+	}
+
+	return normalised, nil
+}
+
+// transform applies the transformation in the given direction to the points, in place.
+func (r *Reprojector) transform(direction proj.Direction, points []orb.Point) error {
+	pj, ok := r.pool.Get().(*proj.PJ)
+	if !ok {
+		var err error
+		if pj, err = r.newTransformer(); err != nil {
+			return err
+		}
+	}
+	defer r.pool.Put(pj)
+
+	coords := make([]proj.Coord, len(points))
+	for i, point := range points {
+		coords[i] = proj.Coord{point.X(), point.Y()}
+	}
+
+	if err := pj.TransArray(direction, coords); err != nil {
+		return fmt.Errorf("%w: %s to %s: %v", ErrProjection, ProjectedCRS, r.targetCRS, err)
+	}
+
+	for i, coord := range coords {
+		points[i] = orb.Point{coord.X(), coord.Y()}
+	}
+
+	return nil
+}
+
+// Forward takes a projected Easting / Northing point and returns the corresponding target CRS point.
+func (r *Reprojector) Forward(point orb.Point) (orb.Point, error) {
+	points := []orb.Point{point}
+	if err := r.transform(proj.DirectionFwd, points); err != nil {
+		return orb.Point{}, err
+	}
+
+	return points[0], nil
+}
+
+// Inverse takes a target CRS point and returns the corresponding projected Easting / Northing point.
+func (r *Reprojector) Inverse(point orb.Point) (orb.Point, error) {
+	points := []orb.Point{point}
+	if err := r.transform(proj.DirectionInv, points); err != nil {
+		return orb.Point{}, err
+	}
+
+	return points[0], nil
+}
+
+// ForwardMulti takes a slice of projected Easting / Northing points and returns the corresponding target CRS points slice.
+func (r *Reprojector) ForwardMulti(points []orb.Point) ([]orb.Point, error) {
+	transformed := append([]orb.Point(nil), points...)
+	if err := r.transform(proj.DirectionFwd, transformed); err != nil {
+		return nil, err
+	}
+
+	return transformed, nil
+}
+
+// InverseMulti takes a slice of target CRS points and returns the corresponding projected Easting / Northing points slice.
+func (r *Reprojector) InverseMulti(points []orb.Point) ([]orb.Point, error) {
+	transformed := append([]orb.Point(nil), points...)
+	if err := r.transform(proj.DirectionInv, transformed); err != nil {
+		return nil, err
+	}
+
+	return transformed, nil
+}
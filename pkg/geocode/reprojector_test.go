@@ -0,0 +1,108 @@
+package geocode
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestReprojector(t *testing.T) {
+	const Epsilon = 1e-5          // Degrees.
+	const EpsilonRoundTrip = 0.01 // Metres.
+	const EpsilonEN = 1.5         // Metres (test place Longitude / Latitude are rounded to five decimal places).
+
+	r, err := NewReprojector(GeographicCRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPlaces := getTestPlaces()
+	planarPoints := []orb.Point{}
+	for _, testPlace := range testPlaces {
+		planarPoints = append(planarPoints, orb.Point{float64(testPlace.easting), float64(testPlace.northing)})
+	}
+
+	geoPoints, err := r.ForwardMulti(planarPoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enPoints, err := r.InverseMulti(geoPoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, testPlace := range testPlaces {
+		if math.Abs(geoPoints[i].X()-testPlace.lonLat.X()) > Epsilon || math.Abs(geoPoints[i].Y()-testPlace.lonLat.Y()) > Epsilon {
+			t.Errorf("ForwardMulti error, should be: %v but got: %v (%s)", testPlace.lonLat, geoPoints[i], testPlace.name)
+		}
+
+		if math.Abs(enPoints[i].X()-planarPoints[i].X()) > EpsilonRoundTrip || math.Abs(enPoints[i].Y()-planarPoints[i].Y()) > EpsilonRoundTrip {
+			t.Errorf("InverseMulti error, should be: %v but got: %v (%s)", planarPoints[i], enPoints[i], testPlace.name)
+		}
+
+		geoPoint, err := r.Forward(planarPoints[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if geoPoint != geoPoints[i] {
+			t.Errorf("Forward error, should be: %v but got: %v (%s)", geoPoints[i], geoPoint, testPlace.name)
+		}
+
+		enPoint, err := r.Inverse(testPlace.lonLat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(enPoint.X()-planarPoints[i].X()) > EpsilonEN || math.Abs(enPoint.Y()-planarPoints[i].Y()) > EpsilonEN {
+			t.Errorf("Inverse error, should be: %v but got: %v (%s)", planarPoints[i], enPoint, testPlace.name)
+		}
+	}
+}
+
+func TestReprojectorConcurrent(t *testing.T) {
+	r, err := NewReprojector(GeographicCRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPlace := getTestPlaces()[0]
+	pt := orb.Point{float64(testPlace.easting), float64(testPlace.northing)}
+	want, err := r.Forward(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				got, err := r.Forward(pt)
+				if err != nil || got != want {
+					t.Errorf("expected %v, but got %v (%v)", want, got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReprojectorErrors(t *testing.T) {
+	if _, err := NewReprojector("EPSG:0"); !errors.Is(err, ErrProjection) {
+		t.Errorf("expected ErrProjection for invalid CRS, but got %v", err)
+	}
+
+	r, err := NewReprojector(GeographicCRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Inverse(orb.Point{0, 999}); !errors.Is(err, ErrProjection) {
+		t.Errorf("expected ErrProjection for invalid latitude, but got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Grid to ground scale factor of the OSGB36 National Grid Transverse Mercator projection.
+
+package geocode
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+const (
+	osgbCentralScale    = 0.999_601_271_7 // Scale factor on the central meridian (F0).
+	osgbFalseEasting    = 400_000.0       // Easting of the central meridian (metres).
+	osgbGaussianRadius  = 6_384_130.0     // Airy 1830 Gaussian mean radius of curvature at 54°N (metres).
+	groundSimpsonWeight = 4.0             // Simpson's rule weight of the segment midpoint scale factor.
+)
+
+// ScaleFactor returns the point scale factor (grid distance / ground distance) of the National Grid at the
+// Easting / Northing point, from about 0.9996 on the central meridian to over 1.0004 at the edges of Britain.
+// The radius of curvature is taken as constant across Britain, introducing an error of less than 1e-6.
+func ScaleFactor(pt orb.Point) float64 {
+	x := (pt.X() - osgbFalseEasting) / osgbCentralScale / osgbGaussianRadius
+	x2 := x * x
+	return osgbCentralScale * (1 + x2/2 + x2*x2/24)
+}
+
+// GroundDistance returns the ground (ellipsoidal) distance (metres) between two Easting / Northing points,
+// correcting the grid distance by the scale factor averaged along the line (Simpson's rule).
+func GroundDistance(fromPoint, toPoint orb.Point) float64 {
+	midPoint := interpolatePoint(fromPoint, toPoint, 0.5)
+	scale := (ScaleFactor(fromPoint) + groundSimpsonWeight*ScaleFactor(midPoint) + ScaleFactor(toPoint)) / (groundSimpsonWeight + 2)
+	return planar.Distance(fromPoint, toPoint) / scale
+}
+
+// GroundLength returns the ground (ellipsoidal) length (metres) of the Easting / Northing linestring.
+func GroundLength(line orb.LineString) float64 {
+	length := 0.0
+	for i := 1; i < len(line); i++ {
+		length += GroundDistance(line[i-1], line[i])
+	}
+
+	return length
+}
+
+// GroundMeasure returns the ground (ellipsoidal) distance (metres) along the linestring from its start to the
+// located point, in place of the grid distance of the location measure.
+func GroundMeasure(line orb.LineString, loc LineLocation) float64 {
+	measure := GroundLength(line[:loc.Segment+1])
+	return measure + loc.Fraction*GroundDistance(line[loc.Segment], line[loc.Segment+1])
+}
@@ -0,0 +1,58 @@
+package geocode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestScaleFactor(t *testing.T) {
+	// Expected values from the Transverse Mercator scale factor series for the National Grid projection parameters.
+	cases := []struct {
+		name     string
+		point    orb.Point
+		expected float64
+	}{
+		{"Central meridian", orb.Point{400_000, 500_000}, 0.999_601_271_7},
+		{"Caister", orb.Point{651_409.903, 313_177.270}, 1.000_377_9},
+		{"West of Cornwall", orb.Point{140_000, 30_000}, 1.000_431},
+		{"Symmetric west", orb.Point{148_590.097, 313_177.270}, 1.000_377_9},
+	}
+
+	for _, c := range cases {
+		got := ScaleFactor(c.point)
+		if math.Abs(got-c.expected) > 1e-6 {
+			t.Errorf("%s: expected %.7f, but got %.7f", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestGroundDistance(t *testing.T) {
+	// On the central meridian ground distance exceeds grid distance; at the edges it is less.
+	central := GroundDistance(orb.Point{400_000, 100_000}, orb.Point{400_000, 101_000})
+	if math.Abs(central-1_000/0.999_601_271_7) > 1e-6 {
+		t.Errorf("central: expected %.6f, but got %.6f", 1_000/0.999_601_271_7, central)
+	}
+
+	east := GroundDistance(orb.Point{650_000, 300_000}, orb.Point{651_000, 300_000})
+	if east >= 1_000 || east < 999.6 {
+		t.Errorf("east: expected ground distance slightly less than 1000, but got %.6f", east)
+	}
+
+	line := orb.LineString{{400_000, 100_000}, {400_000, 101_000}, {401_000, 101_000}}
+	loc, ok := LocateOnLine(line, orb.Point{400_500, 101_010})
+	if !ok {
+		t.Fatal("expected location")
+	}
+
+	expected := GroundDistance(line[0], line[1]) + GroundDistance(line[1], orb.Point{400_500, 101_000})
+	// Scale factor varies along the segment, so the measure is approximately proportional within it.
+	if got := GroundMeasure(line, loc); math.Abs(got-expected) > 1e-5 {
+		t.Errorf("measure: expected %.6f, but got %.6f", expected, got)
+	}
+
+	if got := GroundLength(line); math.Abs(got-GroundDistance(line[0], line[1])-GroundDistance(line[1], line[2])) > 1e-9 {
+		t.Errorf("length: unexpected %.6f", got)
+	}
+}
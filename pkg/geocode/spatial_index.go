@@ -0,0 +1,381 @@
+// In-memory spatial index of ELR centre-line segments, as a Sort-Tile-Recursive (STR) packed R-tree.
+
+package geocode
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+)
+
+const indexNodeCapacity = 16 // Maximum number of children per R-tree node.
+
+// ELRDistance represents an ELR and its distance (metres) from a point.
+type ELRDistance struct {
+	ELR      string  // ELR code.
+	Distance float64 // Distance from the point to the nearest part of the ELR centre-line (metres).
+}
+
+// indexItem represents a single ELR centre-line segment, or the bounding box of a whole ELR, held in the leaves
+// of the R-tree. Points are copied, so that the index never refers to (possibly memory-mapped) ELR geometry.
+type indexItem struct {
+	a   orb.Point // Start point of the segment, or minimum of the bounding box.
+	b   orb.Point // End point of the segment, or maximum of the bounding box.
+	elr int32     // Index of the ELR within the spatial index ELR names.
+}
+
+// bound returns the bounding box of the item.
+func (it indexItem) bound() orb.Bound {
+	return orb.Bound{Min: it.a, Max: it.a}.Extend(it.b)
+}
+
+// indexNode represents an R-tree node, covering a contiguous range of children in the level below.
+type indexNode struct {
+	bound orb.Bound // Bounding box of all children.
+	start int32     // Index of the first child.
+	end   int32     // Index after the last child.
+}
+
+// spatialIndex represents a packed R-tree over the segments of all ELR centre-lines, or over the bounding boxes of
+// all ELRs, with candidates refined against geometry loaded on demand.
+type spatialIndex struct {
+	names    []string                                 // ELR codes.
+	items    []indexItem                              // Leaf entries (segments or ELR bounding boxes).
+	levels   [][]indexNode                            // Tree levels; levels[0] covers the items, and the final level is the single root.
+	geometry func(elr string) (orb.LineString, error) // Loads the geometry of an ELR if items are bounding boxes, otherwise nil.
+}
+
+// newSpatialIndex builds a packed R-tree over the segments of the ELR centre-lines.
+func newSpatialIndex(elrs map[string]ELR) *spatialIndex {
+	idx := &spatialIndex{names: make([]string, 0, len(elrs))}
+
+	for elr := range elrs {
+		idx.names = append(idx.names, elr)
+	}
+	sort.Strings(idx.names)
+
+	count := 0
+	for _, elr := range idx.names {
+		if line := elrs[elr].Geometry; len(line) > 1 {
+			count += len(line) - 1
+		}
+	}
+
+	idx.items = make([]indexItem, 0, count)
+	for i, elr := range idx.names {
+		line := elrs[elr].Geometry
+		for j := 0; j < len(line)-1; j++ {
+			idx.items = append(idx.items, indexItem{a: line[j], b: line[j+1], elr: int32(i)})
+		}
+	}
+
+	idx.pack()
+	return idx
+}
+
+// newBoundIndex builds a packed R-tree over the bounding boxes of the ELR centre-lines, refining candidates
+// against the geometry of each ELR loaded on demand, so that no geometry is held by the index.
+func newBoundIndex(bounds map[string]orb.Bound, geometry func(elr string) (orb.LineString, error)) *spatialIndex {
+	idx := &spatialIndex{names: make([]string, 0, len(bounds)), geometry: geometry}
+
+	for elr := range bounds {
+		idx.names = append(idx.names, elr)
+	}
+	sort.Strings(idx.names)
+
+	idx.items = make([]indexItem, 0, len(idx.names))
+	for i, elr := range idx.names {
+		idx.items = append(idx.items, indexItem{a: bounds[elr].Min, b: bounds[elr].Max, elr: int32(i)})
+	}
+
+	idx.pack()
+	return idx
+}
+
+// pack builds the tree levels over the items.
+func (idx *spatialIndex) pack() {
+	if len(idx.items) == 0 {
+		return
+	}
+
+	// Pack the leaf items, then successively pack each level of nodes until a single root remains.
+	sortTileRecursive(len(idx.items),
+		func(i int) orb.Point { return idx.items[i].bound().Center() },
+		func(i, j int) { idx.items[i], idx.items[j] = idx.items[j], idx.items[i] })
+	level := packNodes(len(idx.items), func(i int) orb.Bound { return idx.items[i].bound() })
+	idx.levels = append(idx.levels, level)
+
+	for len(level) > 1 {
+		below := level
+		sortTileRecursive(len(below),
+			func(i int) orb.Point { return below[i].bound.Center() },
+			func(i, j int) { below[i], below[j] = below[j], below[i] })
+		level = packNodes(len(below), func(i int) orb.Bound { return below[i].bound })
+		idx.levels = append(idx.levels, level)
+	}
+}
+
+// sortTileRecursive orders n entries into vertical slices by centre X, then each slice by centre Y,
+// so that consecutive runs of indexNodeCapacity entries are spatially compact.
+func sortTileRecursive(n int, centre func(int) orb.Point, swap func(i, j int)) {
+	sort.Sort(entrySorter{n, func(i, j int) bool { return centre(i).X() < centre(j).X() }, swap})
+
+	nodeCount := (n + indexNodeCapacity - 1) / indexNodeCapacity
+	sliceCount := int(math.Ceil(math.Sqrt(float64(nodeCount))))
+	sliceSize := sliceCount * indexNodeCapacity
+
+	for start := 0; start < n; start += sliceSize {
+		end := min(start+sliceSize, n)
+		sort.Sort(entrySorter{end - start,
+			func(i, j int) bool { return centre(start+i).Y() < centre(start+j).Y() },
+			func(i, j int) { swap(start+i, start+j) }})
+	}
+}
+
+// packNodes groups consecutive runs of entries into nodes.
+func packNodes(n int, bound func(int) orb.Bound) []indexNode {
+	nodes := make([]indexNode, 0, (n+indexNodeCapacity-1)/indexNodeCapacity)
+
+	for start := 0; start < n; start += indexNodeCapacity {
+		end := min(start+indexNodeCapacity, n)
+		b := bound(start)
+		for i := start + 1; i < end; i++ {
+			b = b.Union(bound(i))
+		}
+		nodes = append(nodes, indexNode{bound: b, start: int32(start), end: int32(end)})
+	}
+
+	return nodes
+}
+
+// entrySorter adapts index-based accessors to sort.Interface.
+type entrySorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+}
+
+func (s entrySorter) Len() int           { return s.n }
+func (s entrySorter) Less(i, j int) bool { return s.less(i, j) }
+func (s entrySorter) Swap(i, j int)      { s.swap(i, j) }
+
+// inBound returns the (alphabetically sorted) ELRs with at least one segment intersecting the bounding box.
+func (idx *spatialIndex) inBound(b orb.Bound) ([]string, error) {
+	if len(idx.levels) == 0 {
+		return []string{}, nil
+	}
+
+	found := make(map[int32]bool)
+	idx.search(len(idx.levels)-1, 0, b, found)
+
+	elrs := make([]string, 0, len(found))
+	for i := range found {
+		if idx.geometry != nil {
+			line, err := idx.geometry(idx.names[i])
+			if err != nil {
+				return nil, err
+			}
+			if !lineIntersectsBound(line, b) {
+				continue
+			}
+		}
+		elrs = append(elrs, idx.names[i])
+	}
+
+	sort.Strings(elrs)
+	return elrs, nil
+}
+
+// lineIntersectsBound reports whether the bounding box of any segment of the line intersects the bounding box.
+func lineIntersectsBound(line orb.LineString, b orb.Bound) bool {
+	for j := 0; j < len(line)-1; j++ {
+		if (orb.Bound{Min: line[j], Max: line[j]}).Extend(line[j+1]).Intersects(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// search recursively descends the R-tree from the node, recording the ELRs of intersecting items.
+func (idx *spatialIndex) search(level, node int, b orb.Bound, found map[int32]bool) {
+	n := idx.levels[level][node]
+	if !n.bound.Intersects(b) {
+		return
+	}
+
+	for i := n.start; i < n.end; i++ {
+		if level > 0 {
+			idx.search(level-1, int(i), b, found)
+		} else if item := idx.items[i]; !found[item.elr] && item.bound().Intersects(b) {
+			found[item.elr] = true
+		}
+	}
+}
+
+// nearest returns up to k ELRs in order of increasing distance from the point, not exceeding the maximum distance.
+// The tree is traversed best-first, so ELRs are produced in distance order without a full scan. ELR bounding
+// boxes are queued by their distance, and refined to the exact distance of the geometry only when reached.
+func (idx *spatialIndex) nearest(pt orb.Point, k int, maxDist float64) ([]ELRDistance, error) {
+	results := make([]ELRDistance, 0, min(max(k, 0), len(idx.names)))
+	if len(idx.levels) == 0 || k <= 0 {
+		return results, nil
+	}
+
+	seen := make(map[int32]bool)
+	queue := &indexQueue{{distance: 0, level: len(idx.levels) - 1, entry: 0}}
+
+	for queue.Len() > 0 && len(results) < k {
+		e := heap.Pop(queue).(indexQueueEntry)
+		if e.distance > maxDist {
+			break
+		}
+
+		if e.level == -1 {
+			// Item with exact segment distance; the first occurrence of each ELR is its nearest.
+			item := idx.items[e.entry]
+			if !seen[item.elr] {
+				seen[item.elr] = true
+				results = append(results, ELRDistance{ELR: idx.names[item.elr], Distance: e.distance})
+			}
+			continue
+		}
+
+		if e.level == boundItemLevel {
+			// ELR bounding box; requeue with the exact distance of its geometry.
+			line, err := idx.geometry(idx.names[idx.items[e.entry].elr])
+			if err != nil {
+				return nil, err
+			}
+			_, distance := NearestPointOnLine(&line, pt)
+			heap.Push(queue, indexQueueEntry{distance, -1, e.entry})
+			continue
+		}
+
+		n := idx.levels[e.level][e.entry]
+		for i := n.start; i < n.end; i++ {
+			if e.level > 0 {
+				heap.Push(queue, indexQueueEntry{boundDistance(idx.levels[e.level-1][i].bound, pt), e.level - 1, int(i)})
+			} else if item := idx.items[i]; seen[item.elr] {
+				continue
+			} else if idx.geometry != nil {
+				heap.Push(queue, indexQueueEntry{boundDistance(item.bound(), pt), boundItemLevel, int(i)})
+			} else {
+				_, distance := nearestPointOnSegment(item.a, item.b, pt)
+				heap.Push(queue, indexQueueEntry{distance, -1, int(i)})
+			}
+		}
+	}
+
+	// Equidistant ELRs are ordered alphabetically, for deterministic output.
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Distance == results[j].Distance {
+			return results[i].ELR < results[j].ELR
+		}
+		return results[i].Distance < results[j].Distance
+	})
+
+	return results, nil
+}
+
+// boundDistance returns the minimum distance from the point to the bounding box (zero if contained).
+func boundDistance(b orb.Bound, pt orb.Point) float64 {
+	dx := max(b.Min.X()-pt.X(), 0, pt.X()-b.Max.X())
+	dy := max(b.Min.Y()-pt.Y(), 0, pt.Y()-b.Max.Y())
+	return math.Hypot(dx, dy)
+}
+
+// boundItemLevel is the queue level of an ELR bounding box item, keyed by the distance to the box rather than the ELR.
+const boundItemLevel = -2
+
+// indexQueueEntry represents a node (level >= 0), item (level -1) or unrefined ELR bounding box item
+// (boundItemLevel) awaiting traversal, keyed by distance.
+type indexQueueEntry struct {
+	distance float64
+	level    int
+	entry    int
+}
+
+// indexQueue is a min-heap of entries ordered by distance.
+type indexQueue []indexQueueEntry
+
+func (q indexQueue) Len() int           { return len(q) }
+func (q indexQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+func (q indexQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *indexQueue) Push(x any)        { *q = append(*q, x.(indexQueueEntry)) }
+func (q *indexQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// spatialIndex returns the spatial index of ELR centre-lines, building it on first use.
+// If lazy loading, the index holds only the bounding box of each ELR, with geometry loaded as resident ELRs
+// when refining candidates. Returns ErrClosed once the Geocoder is closed, or the error building the index.
+func (gc *Geocoder) spatialIndex() (*spatialIndex, error) {
+	if gc.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	gc.indexOnce.Do(func() {
+		if gc.lazy == nil {
+			gc.index.Store(newSpatialIndex(gc.ELRs))
+			return
+		}
+
+		bounds, err := gc.lazy.bounds()
+		if err != nil {
+			gc.indexErr = fmt.Errorf("failed to build spatial index: %w", err)
+			return
+		}
+		gc.index.Store(newBoundIndex(bounds, func(elr string) (orb.LineString, error) {
+			e, err := gc.elr(elr)
+			return e.Geometry, err
+		}))
+	})
+
+	if gc.indexErr != nil {
+		return nil, gc.indexErr
+	}
+
+	idx := gc.index.Load()
+	if idx == nil {
+		// Closed since the check above.
+		return nil, ErrClosed
+	}
+
+	return idx, nil
+}
+
+// ELRsInBBox returns the ELRs (in alphabetical order) with centre-lines passing through the Easting / Northing bounding box.
+func (gc *Geocoder) ELRsInBBox(b orb.Bound) ([]string, error) {
+	idx, err := gc.spatialIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.inBound(b)
+}
+
+// NearestELRs returns the k nearest ELRs to the Easting / Northing point, in order of increasing distance.
+func (gc *Geocoder) NearestELRs(pt orb.Point, k int) ([]ELRDistance, error) {
+	idx, err := gc.spatialIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.nearest(pt, k, math.Inf(1))
+}
+
+// ELRsWithin returns the ELRs within the radius (metres) of the Easting / Northing point, in order of increasing distance.
+func (gc *Geocoder) ELRsWithin(pt orb.Point, radius float64) ([]ELRDistance, error) {
+	idx, err := gc.spatialIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.nearest(pt, math.MaxInt, radius)
+}
@@ -0,0 +1,203 @@
+package geocode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// gridGeocoder returns a geocoder with horizontal ELRs at 100 metre northing intervals.
+func gridGeocoder(count int) *Geocoder {
+	gc := &Geocoder{ELRs: make(map[string]ELR, count)}
+	for i := 0; i < count; i++ {
+		y := float64(i * 100)
+		line := orb.LineString{}
+		for x := 0.0; x <= 1_000; x += 50 {
+			line = append(line, orb.Point{x, y})
+		}
+		gc.ELRs[fmt.Sprintf("E%03d", i)] = ELR{Geometry: line}
+	}
+
+	return gc
+}
+
+func TestELRsInBBox(t *testing.T) {
+	gc := gridGeocoder(50)
+
+	cases := []struct {
+		name     string
+		bound    orb.Bound
+		expected []string
+	}{
+		{
+			name:     "Single ELR",
+			bound:    orb.Bound{Min: orb.Point{10, 190}, Max: orb.Point{20, 210}},
+			expected: []string{"E002"},
+		},
+		{
+			name:     "Several ELRs",
+			bound:    orb.Bound{Min: orb.Point{900, 4_650}, Max: orb.Point{1_200, 4_900}},
+			expected: []string{"E047", "E048", "E049"},
+		},
+		{
+			name:     "Between ELRs",
+			bound:    orb.Bound{Min: orb.Point{0, 110}, Max: orb.Point{1_000, 190}},
+			expected: []string{},
+		},
+		{
+			name:     "Beyond all ELRs",
+			bound:    orb.Bound{Min: orb.Point{2_000, 0}, Max: orb.Point{3_000, 1_000}},
+			expected: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		got, err := gc.ELRsInBBox(c.bound)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: expected %v, but got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestNearestELRs(t *testing.T) {
+	gc := gridGeocoder(50)
+
+	got, err := gc.NearestELRs(orb.Point{500, 1_030}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ELRDistance{{"E010", 30}, {"E011", 70}, {"E009", 130}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, but got %v", expected, got)
+	}
+
+	// Beyond the end of the ELRs, the distance is to the nearest end point.
+	got, _ = gc.NearestELRs(orb.Point{1_030, -40}, 1)
+	if len(got) != 1 || got[0].ELR != "E000" || math.Abs(got[0].Distance-50) > 1e-9 {
+		t.Errorf("expected E000 at 50m, but got %v", got)
+	}
+
+	if got, _ := gc.NearestELRs(orb.Point{0, 0}, 0); len(got) != 0 {
+		t.Errorf("expected no ELRs, but got %v", got)
+	}
+
+	if got, _ := gc.NearestELRs(orb.Point{0, 0}, 1_000); len(got) != 50 {
+		t.Errorf("expected all 50 ELRs, but got %d", len(got))
+	}
+}
+
+func TestELRsWithin(t *testing.T) {
+	gc := gridGeocoder(50)
+
+	got, err := gc.ELRsWithin(orb.Point{500, 2_050}, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ELRDistance{{"E020", 50}, {"E021", 50}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, but got %v", expected, got)
+	}
+
+	if got, _ := gc.ELRsWithin(orb.Point{500, 2_050}, 10); len(got) != 0 {
+		t.Errorf("expected no ELRs, but got %v", got)
+	}
+
+	empty := &Geocoder{ELRs: map[string]ELR{}}
+	if got, _ := empty.ELRsWithin(orb.Point{0, 0}, 1_000); len(got) != 0 {
+		t.Errorf("expected no ELRs, but got %v", got)
+	}
+}
+
+// TestNearestELRsBruteForce compares the R-tree against an exhaustive scan of irregular geometries.
+func TestNearestELRsBruteForce(t *testing.T) {
+	gc := &Geocoder{ELRs: make(map[string]ELR)}
+	for i := 0; i < 200; i++ {
+		line := orb.LineString{}
+		for j := 0; j < 30; j++ {
+			a := float64(i*7919+j*104_729) * 0.001
+			line = append(line, orb.Point{float64(i%20)*500 + 300*math.Sin(a), float64(i/20)*500 + float64(j*20) + 50*math.Cos(a)})
+		}
+		gc.ELRs[fmt.Sprintf("R%03d", i)] = ELR{Geometry: line}
+	}
+
+	for _, pt := range []orb.Point{{1_234, 2_345}, {-500, -500}, {9_000, 4_000}, {5_000, 100}} {
+		got, err := gc.NearestELRs(pt, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		best := math.MaxFloat64
+		for _, e := range gc.ELRs {
+			_, d := NearestPointOnLine(&e.Geometry, pt)
+			best = math.Min(best, d)
+		}
+
+		if len(got) != 5 || math.Abs(got[0].Distance-best) > 1e-9 {
+			t.Errorf("point %v: expected nearest distance %v, but got %v", pt, best, got)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Distance < got[i-1].Distance {
+				t.Errorf("point %v: results not in distance order: %v", pt, got)
+			}
+		}
+	}
+}
+
+// TestBoundIndex compares the index of ELR bounding boxes, refined against geometry, with the index of segments.
+func TestBoundIndex(t *testing.T) {
+	elrs := make(map[string]ELR)
+	bounds := make(map[string]orb.Bound)
+	for i := 0; i < 100; i++ {
+		line := orb.LineString{}
+		for j := 0; j < 20; j++ {
+			a := float64(i*7919+j*104_729) * 0.001
+			line = append(line, orb.Point{float64(i%10)*400 + 600*math.Sin(a), float64(i/10)*400 + float64(j*40) + 80*math.Cos(a)})
+		}
+		elr := fmt.Sprintf("B%03d", i)
+		elrs[elr], bounds[elr] = ELR{Geometry: line}, line.Bound()
+	}
+
+	segments := newSpatialIndex(elrs)
+	loads := 0
+	boxes := newBoundIndex(bounds, func(elr string) (orb.LineString, error) {
+		loads++
+		return elrs[elr].Geometry, nil
+	})
+
+	for _, pt := range []orb.Point{{1_234, 2_345}, {-500, -500}, {5_000, 1_000}, {2_000, 100}} {
+		expected, _ := segments.nearest(pt, 5, math.Inf(1))
+		got, err := boxes.nearest(pt, 5, math.Inf(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("point %v: expected %v, but got %v", pt, expected, got)
+		}
+
+		b := orb.Bound{Min: pt, Max: pt}.Pad(150)
+		expectedIn, _ := segments.inBound(b)
+		gotIn, err := boxes.inBound(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotIn, expectedIn) {
+			t.Errorf("bound %v: expected %v, but got %v", b, expectedIn, gotIn)
+		}
+	}
+
+	if loads == 0 || loads >= 4*len(elrs) {
+		t.Errorf("expected geometry loaded for candidates only, but got %d loads", loads)
+	}
+
+	failing := newBoundIndex(bounds, func(string) (orb.LineString, error) { return nil, errors.New("unavailable") })
+	if _, err := failing.nearest(orb.Point{0, 0}, 1, math.Inf(1)); err == nil {
+		t.Errorf("expected error refining nearest")
+	}
+}
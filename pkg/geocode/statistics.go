@@ -57,8 +57,12 @@ func median(samples []float64, count int) float64 {
 	}
 }
 
-// stdDev calculates the standard deviation of a sample of numbers.
+// stdDev calculates the standard deviation of a sample of numbers, zero for a single number.
 func stdDev(samples []float64, count int, mean float64) float64 {
+	if count < 2 {
+		return 0
+	}
+
 	sumOfSquares := 0.0
 
 	for _, sample := range samples {
@@ -70,9 +74,13 @@ func stdDev(samples []float64, count int, mean float64) float64 {
 	return math.Sqrt(sumOfSquares / float64(count-1))
 }
 
-// Stats calculates the combined statistics of a sample of numbers.
+// Stats calculates the combined statistics of a sample of numbers, all zero for an empty sample.
 func Stats(samples []float64) Statistics {
 	count := len(samples)
+	if count == 0 {
+		return Statistics{}
+	}
+
 	minVal, maxVal := minMax(samples)
 	meanVal := mean(samples, count)
 	medianVal := median(samples, count)
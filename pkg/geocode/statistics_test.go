@@ -72,6 +72,10 @@ func TestStdDev(t *testing.T) {
 	if result != expected {
 		t.Errorf("Expected %f, but got %f", expected, result)
 	}
+
+	if result := stdDev([]float64{3.0}, 1, 3.0); result != 0 {
+		t.Errorf("Expected 0 for a single number, but got %f", result)
+	}
 }
 
 func TestStats(t *testing.T) {
@@ -145,6 +149,13 @@ func TestCollateStats(t *testing.T) {
 			expectedSegmentLen:   Statistics{Count: 3, Min: 100, Max: 100, Mean: 100, Median: 100, StdDev: 0},
 			expectedQmNormalised: Statistics{Count: 3, Min: 420, Max: 470, Mean: 440.0, Median: 430, StdDev: 26.45751},
 		},
+		{
+			name:                 "Test CollateStats with no segments",
+			samples:              nil,
+			expectedAccuracy:     Statistics{},
+			expectedSegmentLen:   Statistics{},
+			expectedQmNormalised: Statistics{},
+		},
 	}
 
 	for _, tt := range tests {
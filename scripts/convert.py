@@ -122,9 +122,10 @@ def convert_mileposts() -> None:
     mileposts.to_file(CONFIG["mp_db"], driver="SQLite", layer="mp", engine="pyogrio")  # type: ignore
 
     # Create SQLite composite index on Milepost table to reduce calibration time.
+    # Not unique, as duplicate mileposts are reported by the builder's milepost audit (and resolved by the calibrator, retaining the post nearest the centre-line).
     conn = sqlite3.connect(CONFIG["mp_db"])
     cur = conn.cursor()
-    cur.execute("CREATE UNIQUE INDEX ix_elr_total_yards ON mp (elr, total_yards_from)")
+    cur.execute("CREATE INDEX ix_elr_total_yards ON mp (elr, total_yards_from)")
     conn.commit()
     conn.close()
 
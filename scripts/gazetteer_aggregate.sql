@@ -4,6 +4,7 @@ CREATE TABLE gazetteer_grouping (group_id INTEGER, group_name VARCHAR NOT NULL,
 INSERT INTO gazetteer_grouping (group_id, group_name) VALUES (1, 'nr_region');
 INSERT INTO gazetteer_grouping (group_id, group_name) VALUES (2, 'country_admin_area'); -- Country and admin area name railway point is within.
 INSERT INTO gazetteer_grouping (group_id, group_name) VALUES (3, 'district_place');  -- District and place name of nearest place to railway point.
+INSERT INTO gazetteer_grouping (group_id, group_name) VALUES (4, 'calibration_grade');  -- Calibration grade (A to E) of the railway.
 
 
 CREATE TABLE gazetteer_aggregated (
@@ -104,6 +105,27 @@ ORDER BY
     elr, offset_from;
 
 
+CREATE TABLE gazetteer_by_calibration_grade (
+    elr VARCHAR NOT NULL, 
+    offset_from INT NOT NULL, 
+    offset_to INT NOT NULL, 
+    mileage_from VARCHAR NOT NULL, 
+    mileage_to VARCHAR NOT NULL, 
+    grade VARCHAR NOT NULL, 
+    PRIMARY KEY (elr, offset_from, offset_to)
+);
+
+INSERT INTO gazetteer_by_calibration_grade (elr, offset_from, offset_to, mileage_from, mileage_to, grade)
+SELECT
+    elr, offset_from, offset_to, mileage_from, mileage_to, value_1 
+FROM
+    gazetteer_aggregated 
+WHERE
+    group_id = (SELECT group_id FROM gazetteer_grouping WHERE group_name = 'calibration_grade')
+ORDER BY
+    elr, offset_from;
+
+
 CREATE TABLE elr_by_country_admin_area AS
 SELECT country, admin_area, GROUP_CONCAT(elr, ";") AS elrs
 FROM (
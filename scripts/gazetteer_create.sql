@@ -12,6 +12,7 @@ CREATE TABLE gazetteer (
 	latitude VARCHAR NOT NULL,
 	osgr VARCHAR NOT NULL,
 	accuracy INTEGER NOT NULL,
+	grade VARCHAR NOT NULL,
 	nr_region VARCHAR NULL,
 	place_name VARCHAR NOT NULL,
 	district VARCHAR NULL,